@@ -19,15 +19,17 @@ type action interface {
 	// formatted as (<leftAction>,<rightAction>), regardless of whether the left or right action is nil. In
 	// which case, the action is formatted as (<leftAction>,) or (,<rightAction>).
 	string() string
-	// apply applies the action to the field and returns the result of the action.
-	apply(fld field) []field
+	// apply applies the action to the field and returns the result of the action. An error is returned if the action
+	// could not be applied.
+	apply(fld field) ([]field, error)
 }
 
 // newAction parses an action string in Geneva syntax and returns a ChangecaseAction, InsertAction, ReplaceAction,
 // or DuplicateAction as an Action with the subsequent left and right action branches configured. If left or right
 // is nil, the corresponding action is automatically set to TerminateAction. For ChangecaseAction, InsertAction,
-// and ReplaceAction, left is configured as the next action. newAction returns an error if action is not a valid
-// action or is formatted incorrectly.
+// and ReplaceAction, left is configured as the next action. Any other action name is looked up in the actions
+// registered with RegisterAction. newAction returns an error if action is not a valid action or is formatted
+// incorrectly.
 func newAction(actionstr string, left, right action) (action, error) {
 	br := strings.Index(actionstr, "{")
 	var args []string
@@ -50,17 +52,31 @@ func newAction(actionstr string, left, right action) (action, error) {
 
 	switch actionstr {
 	case "changecase":
-		if len(args) != 1 {
-			return nil, errors.New("changecase requires 1 argument")
+		var preserveEscapes bool
+		switch len(args) {
+		case 1:
+		case 2:
+			// if an escape mode is given, it must be "preserve" to leave percent-escapes unchanged or "escapes" to
+			// change the case of their hex digits too, which is the default.
+			switch args[1] {
+			case "preserve":
+				preserveEscapes = true
+			case "escapes":
+			default:
+				return nil, fmt.Errorf("invalid changecase escape mode: %s", args[1])
+			}
+		default:
+			return nil, errors.New("changecase must be changecase{upper|lower} or changecase{upper|lower:preserve|escapes}")
 		}
 
-		return newChangecaseAction(args[0], left)
+		return newChangecaseAction(args[0], preserveEscapes, left)
 	case "insert":
 		n := 1
+		var encoding string
 		switch len(args) {
 		case 3:
 			// default to 1 copy if no number of copies is given
-		case 4:
+		case 4, 5:
 			// if a number of copies is given, parse it and return an error if it is not an int
 			if args[3] != "" {
 				var err error
@@ -68,17 +84,25 @@ func newAction(actionstr string, left, right action) (action, error) {
 					return nil, fmt.Errorf("insert number of copies (%q) must be an int", args[3])
 				}
 			}
+
+			// if an encoding is given, newInsertAction checks that it's valid
+			if len(args) == 5 {
+				encoding = args[4]
+			}
 		default:
-			return nil, errors.New("insert requires 3 or 4 arguments. 'num' is optional and defaults to 1")
+			return nil, errors.New(
+				"insert requires 3 to 5 arguments. 'num' and 'encoding' are optional and default to 1 and raw",
+			)
 		}
 
-		return newInsertAction(args[0], args[1], args[2], n, left)
+		return newInsertAction(args[0], args[1], args[2], n, encoding, left)
 	case "replace":
 		n := 1
+		var encoding string
 		switch len(args) {
 		case 2:
 			// default to 1 copy if no number of copies is given
-		case 3:
+		case 3, 4:
 			// if a number of copies is given, parse it and return an error if it is not an int
 			if args[2] != "" {
 				var err error
@@ -86,11 +110,56 @@ func newAction(actionstr string, left, right action) (action, error) {
 					return nil, fmt.Errorf("replace number of copies (%q) must be an int", args[2])
 				}
 			}
+
+			// if an encoding is given, newReplaceAction checks that it's valid
+			if len(args) == 4 {
+				encoding = args[3]
+			}
+		default:
+			return nil, errors.New(
+				"replace requires 2 to 4 arguments. 'num' and 'encoding' are optional and default to 1 and raw",
+			)
+		}
+
+		return newReplaceAction(args[0], args[1], n, encoding, left)
+	case "append", "prepend":
+		n := 1
+		var encoding string
+		switch len(args) {
+		case 2:
+			// default to 1 copy if no number of copies is given
+		case 3, 4:
+			// if a number of copies is given, parse it and return an error if it is not an int
+			if args[2] != "" {
+				var err error
+				if n, err = strconv.Atoi(args[2]); err != nil {
+					return nil, fmt.Errorf("%s number of copies (%q) must be an int", actionstr, args[2])
+				}
+			}
+
+			// if an encoding is given, newInsertAction checks that it's valid
+			if len(args) == 4 {
+				encoding = args[3]
+			}
 		default:
-			return nil, errors.New("replace requires 2 or 3 arguments. 'num' is optional and defaults to 1")
+			return nil, fmt.Errorf(
+				"%s requires 2 to 4 arguments. 'num' and 'encoding' are optional and default to 1 and raw", actionstr,
+			)
 		}
 
-		return newReplaceAction(args[0], args[1], n, left)
+		// append and prepend are insert at the end and start, but keep their own name so they round-trip.
+		l := "end"
+		if actionstr == "prepend" {
+			l = "start"
+		}
+
+		a, err := newInsertAction(args[0], l, args[1], n, encoding, left)
+		if err != nil {
+			return nil, err
+		}
+
+		a.alias = actionstr
+		return a, nil
 	case "duplicate":
 		// duplicate action does not support arguments so return an error if the argument list is not empty
 		if len(args) != 0 {
@@ -99,7 +168,8 @@ func newAction(actionstr string, left, right action) (action, error) {
 
 		return newDuplicateAction(left, right), nil
 	default:
-		return nil, fmt.Errorf("unknown action: %s", actionstr)
+		// the action might have been registered with RegisterAction.
+		return newCustomAction(actionstr, args, left)
 	}
 }
 
@@ -107,10 +177,28 @@ func newAction(actionstr string, left, right action) (action, error) {
 type field struct {
 	// name is the header name of the field.
 	name string
-	// value is the value of the header or the entire field if the field is not a header.
+	// value is the value of the header or the entire field if the field is not a header. For headers, value does
+	// not include the optional whitespace following the colon.
 	value string
+	// ows is the optional whitespace between the colon and the value of a header. It is kept separate from value so
+	// actions applied to the start of the value do not end up before it.
+	ows string
+	// trailingOWS is the optional whitespace after the value of a header. Like ows, it is kept separate from value so
+	// triggers match the value without it and actions applied to the end of the value do not end up after it.
+	trailingOWS string
 	// isHeader is true if the field is a header, otherwise it is false.
 	isHeader bool
+	// randSeed, if not zero, is used instead of the random number generator to choose random locations so that every
+	// copy of the field made by a duplicate action uses the same location.
+	randSeed uint64
+	// vars holds the values of the variables that action values can reference, keyed by name. It is set from the
+	// request when a rule is applied. See actionVariables.
+	vars map[string]string
+}
+
+// String returns a string representation of the field.
+func (f field) String() string {
+	return fmt.Sprintf("name=%s value=%s header=%t", f.name, f.value, f.isHeader)
 }
 
 // changecaseAction changes the case of the field. If the field is a header, changecaseAction will change
@@ -120,43 +208,89 @@ type changecaseAction struct {
 	//   - "upper": changes the field to upper case
 	//   - "lower": changes the field to lower case
 	Case string
+	// preserveEscapes is true if percent-escape sequences, such as %2F, should be left unchanged. This is set with
+	// the optional "preserve" argument. The optional "escapes" argument explicitly selects the default, where the hex
+	// digits of escapes are changed along with the rest of the field.
+	preserveEscapes bool
 	// next is the next action in the action tree.
 	next action
 }
 
 // newChangecaseAction returns a new ChangecaseAction with case c and next action n. If next is nil, it is
-// automatically set to TerminateAction. If c is not "upper" or "lower", newChangecaseAction returns an error.
-func newChangecaseAction(c string, next action) (*changecaseAction, error) {
+// automatically set to TerminateAction. If preserveEscapes is true, percent-escape sequences are not modified. If c
+// is not "upper" or "lower", newChangecaseAction returns an error.
+func newChangecaseAction(c string, preserveEscapes bool, next action) (*changecaseAction, error) {
 	if c != "upper" && c != "lower" {
 		return nil, fmt.Errorf("invalid case: %s", c)
 	}
 
 	return &changecaseAction{
-		Case: c,
-		next: terminateIfNil(next),
+		Case:            c,
+		preserveEscapes: preserveEscapes,
+		next:            terminateIfNil(next),
 	}, nil
 }
 
 // string returns a string representation of the change case action.
 func (a *changecaseAction) string() string {
+	if a.preserveEscapes {
+		return fmt.Sprintf("changecase{%s:preserve}%s", a.Case, nextToString(a.next))
+	}
+
 	return fmt.Sprintf("changecase{%s}%s", a.Case, nextToString(a.next))
 }
 
 // apply changes the case of the field according to the case specified in the action. apply calls
 // the next action in the action tree.
-func (a *changecaseAction) apply(fld field) []field {
+func (a *changecaseAction) apply(fld field) ([]field, error) {
+	var fn func(string) string
 	switch a.Case {
 	case "upper":
-		fld.name = strings.ToUpper(fld.name)
-		fld.value = strings.ToUpper(fld.value)
+		fn = strings.ToUpper
 	case "lower":
-		fld.name = strings.ToLower(fld.name)
-		fld.value = strings.ToLower(fld.value)
+		fn = strings.ToLower
+	default:
+		return a.next.apply(fld)
 	}
 
+	if a.preserveEscapes {
+		fn = skipEscapes(fn)
+	}
+
+	fld.name = fn(fld.name)
+	fld.value = fn(fld.value)
 	return a.next.apply(fld)
 }
 
+// skipEscapes returns a function that applies fn to s, except for any percent-escape sequences ("%XX"), which are
+// left unchanged.
+func skipEscapes(fn func(string) string) func(string) string {
+	return func(s string) string {
+		var sb strings.Builder
+		for {
+			i := strings.IndexByte(s, '%')
+			if i == -1 {
+				sb.WriteString(fn(s))
+				return sb.String()
+			}
+
+			if i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+				sb.WriteString(fn(s[:i]))
+				sb.WriteString(s[i : i+3])
+				s = s[i+3:]
+			} else {
+				// not an escape sequence so apply fn up to and including the '%'
+				sb.WriteString(fn(s[:i+1]))
+				s = s[i+1:]
+			}
+		}
+	}
+}
+
+func isHex(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
+
 // insertAction inserts Value at Location in the Component of the field Num times.
 type insertAction struct {
 	// Value is the value to insert into the field. It is URL encoded with space encoded as %20 instead of "+".
@@ -166,23 +300,34 @@ type insertAction struct {
 	//   - "start": inserts the value at the start of the field
 	//   - "end": inserts the value at the end of the field
 	//   - "middle": inserts the value at len(field)/2
-	//   - "random": inserts the value at a random location, 0 < r < len(field), in the field.
+	//   - "random": inserts the value at a random location, 0 < r < len(field), in the field. If the field is shorter
+	//     than 2 characters, there is no such location, so the value is inserted at the start.
 	location string
 	// component only applies if the field is a header, otherwise it is ignored and InsertAction is
 	// applied to the entire field. component can be one of the following:
 	//   - "name": inserts the value in the name component of the header
 	//   - "value": inserts the value in the value component of the header
 	component string
-	// num is the number of times the value is inserted into the field. If num is <= 0, num is set to 1.
+	// num is the number of times the value is inserted into the field. If num is 0, num is set to 1.
 	num int
+	// encoding is how Value is decoded. It is set with the optional encoding argument and is empty for the default,
+	// raw. See decodeValue.
+	encoding string
+	// variable is the name of the variable Value references if it starts with '$', and is empty otherwise. The value
+	// of the variable is inserted instead of Value. See actionVariables.
+	variable string
+	// alias is "append" or "prepend" if the action was created with one of those names instead of insert, and is
+	// empty otherwise. It only changes how the action is written by string.
+	alias string
 	// next is the next action in the action tree.
 	next action
 }
 
 // newInsertAction returns a new InsertAction with value v, location l, component c, number of copies of the value n,
-// and next action. If next is nil, it is automatically set to TerminateAction. newInsertAction returns an error if c
-// is not "name" or "value" or if l is not "start", "end", "middle", or "random". If n is <= 0, n is set to 1.
-func newInsertAction(v, l, c string, n int, next action) (*insertAction, error) {
+// encoding e, and next action. If next is nil, it is automatically set to TerminateAction. newInsertAction returns an
+// error if c is not "name" or "value", if l is not "start", "end", "middle", or "random", if n is negative, or if v
+// cannot be decoded with e. If n is 0, n is set to 1.
+func newInsertAction(v, l, c string, n int, e string, next action) (*insertAction, error) {
 	if l != "start" && l != "end" && l != "middle" && l != "random" {
 		return nil, fmt.Errorf("invalid location: %s", l)
 	}
@@ -191,56 +336,99 @@ func newInsertAction(v, l, c string, n int, next action) (*insertAction, error)
 		return nil, fmt.Errorf("invalid component: %s", c)
 	}
 
-	if n <= 0 {
+	if n < 0 {
+		return nil, fmt.Errorf("number of copies must not be negative: %d", n)
+	}
+
+	if n == 0 {
 		n = 1
 	}
 
-	// geneva uses URL encoding for the value but with %20 as space instead of +, so we need to unescape it
-	nv, err := url.PathUnescape(v)
+	nv, err := decodeValue(v, e)
 	if err != nil {
-		return nil, fmt.Errorf("invalid value: %s, %w", v, err)
+		return nil, err
+	}
+
+	variable := parseVariable(v)
+
+	// raw is the default, so it's stored as empty so the action is the same whether or not it was given
+	if e == "raw" {
+		e = ""
+	}
+
+	if variable != "" {
+		nv = ""
 	}
 
 	nv = strings.Repeat(nv, n)
 	return &insertAction{
 		Value:     v,
 		value:     nv,
+		variable:  variable,
 		location:  l,
 		component: c,
 		num:       n,
+		encoding:  e,
 		next:      terminateIfNil(next),
 	}, nil
 }
 
 // string returns a string representation of the insert action.
 func (a *insertAction) string() string {
+	if a.alias != "" {
+		args := fmt.Sprintf("%s:%s:%d", a.Value, a.component, a.num)
+		if a.encoding != "" {
+			args += ":" + a.encoding
+		}
+
+		return fmt.Sprintf("%s{%s}%s", a.alias, args, nextToString(a.next))
+	}
+
+	if a.encoding != "" {
+		return fmt.Sprintf(
+			"insert{%s:%s:%s:%d:%s}%s", a.Value, a.location, a.component, a.num, a.encoding, nextToString(a.next),
+		)
+	}
+
 	return fmt.Sprintf("insert{%s:%s:%s:%d}%s", a.Value, a.location, a.component, a.num, nextToString(a.next))
 }
 
 // apply inserts Value at Location in the Component of the field Num times. If the field is a header,
 // Component is used to determine which component of the header to apply the action to. apply calls
 // the next action in the action tree.
-func (a *insertAction) apply(fld field) []field {
-	fld = modifyFieldComponent(fld, a.component, a.insert)
+func (a *insertAction) apply(fld field) ([]field, error) {
+	value := expandValue(a.value, a.variable, a.num, fld)
+	fld = modifyFieldComponent(fld, a.component, func(s string) string {
+		return a.insert(s, value, fld.randSeed)
+	})
+
 	return a.next.apply(fld)
 }
 
-func (i *insertAction) insert(str string) string {
+// insert inserts value into str. If seed is not zero, it determines the random location instead of the random
+// number generator.
+func (i *insertAction) insert(str, value string, seed uint64) string {
 	switch i.location {
 	case "start":
-		return i.value + str
+		return value + str
 	case "end":
-		return str + i.value
+		return str + value
 	case "middle":
-		return str[:len(str)/2] + i.value + str[len(str)/2:]
+		return str[:len(str)/2] + value + str[len(str)/2:]
 	case "random":
+		// there is no position strictly inside a field shorter than 2 characters, so the value is inserted at the
+		// start instead, as it would be for any other location.
 		if len(str) <= 1 {
-			return str
+			return value + str
 		}
 
 		// get a random number between 1 and len(str)-1 to avoid inserting at the start or end of the string
 		n := rand.Intn(len(str)-1) + 1
-		return str[:n] + i.value + str[n:]
+		if seed != 0 {
+			n = int(seed%uint64(len(str)-1)) + 1
+		}
+
+		return str[:n] + value + str[n:]
 	default:
 		return str
 	}
@@ -249,7 +437,8 @@ func (i *insertAction) insert(str string) string {
 // replaceAction replaces the field with Value in the Component of the field with Num copies of Value.
 type replaceAction struct {
 	// Value is the value to replace the field with. It is URL encoded with space encoded as %20 instead of "+".
-	// Delete can be simulated by setting Value to an empty string.
+	// Setting Value to an empty string deletes the component. Deleting the name of a header deletes the whole
+	// header line, and deleting the value of a header leaves only the name and colon, e.g. "Host:".
 	Value string
 	value string
 	// component only applies if the field is a header, otherwise it is ignored and ReplaceAction is
@@ -257,50 +446,142 @@ type replaceAction struct {
 	//   - "name": replaces the name component of the header with the value
 	//   - "value": replaces the value component of the header with the value
 	component string
-	// num is the number of copies of Value to replace the field with. If num is <= 0, num is set to 1.
+	// num is the number of copies of Value to replace the field with. If num is 0, num is set to 1.
 	num int
+	// encoding is how Value is decoded. It is set with the optional encoding argument and is empty for the default,
+	// raw. See decodeValue.
+	encoding string
+	// variable is the name of the variable Value references if it starts with '$', and is empty otherwise. The field
+	// is replaced with the value of the variable instead of Value. See actionVariables.
+	variable string
 	// next is the next action in the action tree.
 	next action
 }
 
-// newReplaceAction returns a new ReplaceAction with value v, component c, number of copies of the value n, and next
-// action. If next is nil, it is automatically set to TerminateAction. newReplaceAction returns an error if c is not
-// "name" or "value".
-func newReplaceAction(v, c string, n int, next action) (*replaceAction, error) {
+// newReplaceAction returns a new ReplaceAction with value v, component c, number of copies of the value n, encoding
+// e, and next action. If next is nil, it is automatically set to TerminateAction. newReplaceAction returns an error
+// if c is not "name" or "value", if n is negative, or if v cannot be decoded with e. If n is 0, n is set to 1.
+func newReplaceAction(v, c string, n int, e string, next action) (*replaceAction, error) {
 	if c != "name" && c != "value" {
 		return nil, fmt.Errorf("invalid component: %s", c)
 	}
 
-	if n <= 0 {
+	if n < 0 {
+		return nil, fmt.Errorf("number of copies must not be negative: %d", n)
+	}
+
+	if n == 0 {
 		n = 1
 	}
 
-	// geneva uses URL encoding for the value but with %20 as space instead of +, so we need to unescape it
-	nv, err := url.PathUnescape(v)
+	nv, err := decodeValue(v, e)
 	if err != nil {
-		return nil, fmt.Errorf("invalid value: %s, %w", v, err)
+		return nil, err
+	}
+
+	variable := parseVariable(v)
+
+	if variable != "" {
+		nv = ""
+	}
+
+	// raw is the default, so it's stored as empty so the action is the same whether or not it was given
+	if e == "raw" {
+		e = ""
 	}
 
 	nv = strings.Repeat(nv, n)
 	return &replaceAction{
 		Value:     v,
 		value:     nv,
+		variable:  variable,
 		component: c,
 		num:       n,
+		encoding:  e,
 		next:      terminateIfNil(next),
 	}, nil
 }
 
+// actionVariables are the names of the variables that the value of an insert or replace action can reference by
+// prefixing it with '$', e.g. insert{$host:end:value}. Each expands to the current value of that part of the request
+// when the rule is applied, or to an empty string if the request doesn't have it:
+//   - "host": the value of the Host header
+//   - "method": the method
+//   - "path": the path
+//
+// Any other value that starts with '$', such as $ or $foo, is a literal value, so strategies written before variables
+// were added keep working. A literal value that starts with the name of a variable, such as $host, can encode the '$'
+// as %24.
+var actionVariables = map[string]bool{"host": true, "method": true, "path": true}
+
+// parseVariable returns the name of the variable that the action value v references, or an empty string if v is not
+// '$' followed by one of actionVariables.
+func parseVariable(v string) string {
+	name, ok := strings.CutPrefix(v, "$")
+	if !ok || !actionVariables[name] {
+		return ""
+	}
+
+	return name
+}
+
+// expandValue returns num copies of the value of variable in fld, or value, which already holds its copies, if
+// variable is empty.
+func expandValue(value, variable string, num int, fld field) string {
+	if variable == "" {
+		return value
+	}
+
+	return strings.Repeat(fld.vars[variable], num)
+}
+
+// decodeValue decodes the value of an insert or replace action according to encoding, which can be one of the
+// following:
+//   - "raw" or "": percent-decodes v with path semantics, so '+' is a literal '+' and a space must be encoded as
+//     %20. This is the default since it's what geneva uses.
+//   - "query": percent-decodes v with query semantics, so '+' is decoded as a space.
+//   - "encoded": v is used as is, without decoding it. v must still be validly percent-encoded.
+func decodeValue(v, encoding string) (string, error) {
+	var (
+		nv  string
+		err error
+	)
+	switch encoding {
+	case "", "raw", "encoded":
+		nv, err = url.PathUnescape(v)
+	case "query":
+		nv, err = url.QueryUnescape(v)
+	default:
+		return "", fmt.Errorf("invalid encoding: %s", encoding)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("invalid value: %s, %w", v, err)
+	}
+
+	// the value is still validated above when encoded so an invalid value is caught either way
+	if encoding == "encoded" {
+		return v, nil
+	}
+
+	return nv, nil
+}
+
 // string returns a string representation of the replace action.
 func (a *replaceAction) string() string {
+	if a.encoding != "" {
+		return fmt.Sprintf("replace{%s:%s:%d:%s}%s", a.Value, a.component, a.num, a.encoding, nextToString(a.next))
+	}
+
 	return fmt.Sprintf("replace{%s:%s:%d}%s", a.Value, a.component, a.num, nextToString(a.next))
 }
 
 // apply replaces the field with Value in the Component of the field with Num copies of Value. apply
 // calls the next action in the action tree.
-func (a *replaceAction) apply(fld field) []field {
+func (a *replaceAction) apply(fld field) ([]field, error) {
+	value := expandValue(a.value, a.variable, a.num, fld)
 	fld = modifyFieldComponent(fld, a.component, func(s string) string {
-		return a.value
+		return value
 	})
 
 	return a.next.apply(fld)
@@ -340,11 +621,18 @@ func (a *duplicateAction) string() string {
 }
 
 // apply duplicates the field and applies LeftAction to the original field and RightAction to the duplicate.
-func (a *duplicateAction) apply(fld field) []field {
-	f0 := a.leftAction.apply(fld)
-	f1 := a.rightAction.apply(fld)
+func (a *duplicateAction) apply(fld field) ([]field, error) {
+	f0, err := a.leftAction.apply(fld)
+	if err != nil {
+		return nil, err
+	}
+
+	f1, err := a.rightAction.apply(fld)
+	if err != nil {
+		return nil, err
+	}
 
-	return append(f0, f1...)
+	return append(f0, f1...), nil
 }
 
 // terminateAction does not apply any modifications to the field or call another action.
@@ -358,8 +646,8 @@ func (a *terminateAction) string() string {
 
 // apply returns field.Name and field.Value concatenated together separated by ":" if field is a header.
 // Otherwise, apply returns field.Value. apply does not call another action.
-func (a *terminateAction) apply(fld field) []field {
-	return []field{fld}
+func (a *terminateAction) apply(fld field) ([]field, error) {
+	return []field{fld}, nil
 }
 
 // nextToString returns a string representation of the next action wrapped in parentheses following
@@ -1,6 +1,7 @@
 package algeneva
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,10 +29,68 @@ func TestNewAction(t *testing.T) {
 			name:    "error: changecase missing args",
 			action:  "changecase",
 			wantErr: true,
+		}, {
+			name:    "error: changecase invalid escape mode",
+			action:  "changecase{upper:ignore}",
+			wantErr: true,
+		}, {
+			name:    "error: changecase too many args",
+			action:  "changecase{upper:preserve:escapes}",
+			wantErr: true,
+		}, {
+			name:   "changecase preserve escapes",
+			action: "changecase{lower:preserve}",
+			want:   &changecaseAction{Case: "lower", preserveEscapes: true, next: &terminateAction{}},
+		}, {
+			name:   "changecase escapes is the default",
+			action: "changecase{lower:escapes}",
+			want:   &changecaseAction{Case: "lower", next: &terminateAction{}},
 		}, {
 			name:    "error: insert missing args",
 			action:  "insert{a0:a1}",
 			wantErr: true,
+		}, {
+			name:    "error: insert invalid encoding",
+			action:  "insert{a:start:value:1:base64}",
+			wantErr: true,
+		}, {
+			name:    "error: insert negative num",
+			action:  "insert{a:start:value:-5}",
+			wantErr: true,
+		}, {
+			name:   "insert omitted num defaults to 1",
+			action: "insert{a:start:value}",
+			want:   &insertAction{Value: "a", value: "a", location: "start", component: "value", num: 1, next: &terminateAction{}},
+		}, {
+			name:   "insert empty num defaults to 1",
+			action: "insert{a:start:value:}",
+			want:   &insertAction{Value: "a", value: "a", location: "start", component: "value", num: 1, next: &terminateAction{}},
+		}, {
+			name:    "error: replace negative num",
+			action:  "replace{a:value:-1}",
+			wantErr: true,
+		}, {
+			name:    "error: append negative num",
+			action:  "append{a:value:-2}",
+			wantErr: true,
+		}, {
+			name:   "insert literal dollar",
+			action: "insert{$cookie:end:value}",
+			want: &insertAction{
+				Value: "$cookie", value: "$cookie", location: "end", component: "value", num: 1, next: &terminateAction{},
+			},
+		}, {
+			name:   "replace literal dollar",
+			action: "replace{$:value}",
+			want:   &replaceAction{Value: "$", value: "$", component: "value", num: 1, next: &terminateAction{}},
+		}, {
+			name:   "replace encoded dollar",
+			action: "replace{%24host:value}",
+			want:   &replaceAction{Value: "%24host", value: "$host", component: "value", num: 1, next: &terminateAction{}},
+		}, {
+			name:   "replace variable",
+			action: "replace{$host:value:2}",
+			want:   &replaceAction{Value: "$host", variable: "host", component: "value", num: 2, next: &terminateAction{}},
 		}, {
 			name:    "error: replace missing args",
 			action:  "replace{a0:a1}",
@@ -54,6 +113,19 @@ func TestNewAction(t *testing.T) {
 	}
 }
 
+func TestNewAction_ChangecaseArgs(t *testing.T) {
+	_, err := newAction("changecase{upper:preserve:escapes}", nil, nil)
+	assert.ErrorContains(t, err, "changecase{upper|lower:preserve|escapes}")
+}
+
+func TestField_String(t *testing.T) {
+	assert.Equal(t,
+		"name=Host value=example.com header=true",
+		field{name: "Host", value: "example.com", ows: " ", isHeader: true}.String(),
+	)
+	assert.Equal(t, "name=path value=/ header=false", field{name: "path", value: "/"}.String())
+}
+
 func TestChangeCaseAction_Apply(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -78,8 +150,67 @@ func TestChangeCaseAction_Apply(t *testing.T) {
 				next: &terminateAction{},
 			}
 
-			got := a.apply(tt.field)
+			got, err := a.apply(tt.field)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got[0])
+		})
+	}
+}
+
+func TestChangeCaseAction_ApplyPreserveEscapes(t *testing.T) {
+	tests := []struct {
+		name  string
+		Case  string
+		field field
+		want  field
+	}{
+		{
+			name:  "lower",
+			Case:  "lower",
+			field: field{name: "path", value: "/Some%2FPath%3f"},
+			want:  field{name: "path", value: "/some%2Fpath%3f"},
+		}, {
+			name:  "upper",
+			Case:  "upper",
+			field: field{name: "path", value: "/some%2fpath%3F"},
+			want:  field{name: "PATH", value: "/SOME%2fPATH%3F"},
+		}, {
+			name:  "not an escape sequence",
+			Case:  "upper",
+			field: field{name: "path", value: "/100%/a%z"},
+			want:  field{name: "PATH", value: "/100%/A%Z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := newChangecaseAction(tt.Case, true, nil)
+			assert.NoError(t, err)
+
+			got, err := a.apply(tt.field)
+			assert.NoError(t, err)
 			assert.Equal(t, tt.want, got[0])
+			assert.Equal(t, "changecase{"+tt.Case+":preserve}", a.string())
+		})
+	}
+}
+
+func TestChangeCaseAction_EscapeMode(t *testing.T) {
+	tests := []struct {
+		action string
+		want   string
+	}{
+		{"changecase{lower:preserve}", "/a%2F"},
+		{"changecase{lower:escapes}", "/a%2f"},
+		{"changecase{lower}", "/a%2f"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			a, err := newAction(tt.action, nil, nil)
+			assert.NoError(t, err)
+
+			got, err := a.apply(field{name: "path", value: "/A%2F"})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got[0].value)
 		})
 	}
 }
@@ -107,6 +238,11 @@ func TestInsertAction_Apply(t *testing.T) {
 			conf:  conf{Value: "[]", Location: "random", Component: "value", Num: 2},
 			field: field{name: "name", value: "vl", isHeader: true},
 			want:  field{name: "name", value: "v[][]l", isHeader: true},
+		}, {
+			name:  "insert random into 1 character falls back to start",
+			conf:  conf{Value: "[]", Location: "random", Component: "value", Num: 1},
+			field: field{name: "name", value: "v", isHeader: true},
+			want:  field{name: "name", value: "[]v", isHeader: true},
 		}, {
 			name:  "insert ignore component=name if not header",
 			conf:  conf{Value: "[]", Location: "start", Component: "name", Num: 2},
@@ -121,16 +257,128 @@ func TestInsertAction_Apply(t *testing.T) {
 				tt.conf.Location,
 				tt.conf.Component,
 				tt.conf.Num,
+				"",
 				nil,
 			)
 			assert.NoError(t, err)
 
-			got := a.apply(tt.field)
+			got, err := a.apply(tt.field)
+			assert.NoError(t, err)
 			assert.Equal(t, tt.want, got[0])
 		})
 	}
 }
 
+func TestInsertAction_ApplyEmpty(t *testing.T) {
+	for _, l := range []string{"start", "end", "middle", "random"} {
+		t.Run(l, func(t *testing.T) {
+			a, err := newInsertAction("ab", l, "value", 1, "", nil)
+			assert.NoError(t, err)
+
+			got, err := a.apply(field{name: "Host", isHeader: true})
+			assert.NoError(t, err)
+			assert.Equal(t, field{name: "Host", value: "ab", isHeader: true}, got[0])
+		})
+	}
+}
+
+func TestAction_Encoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		want   field
+	}{
+		{
+			name:   "raw",
+			action: "insert{%0D%0A:end:value:1:raw}",
+			want:   field{name: "Host", value: "example.com\r\n", isHeader: true},
+		}, {
+			name:   "default raw",
+			action: "insert{%0D%0A:end:value:1}",
+			want:   field{name: "Host", value: "example.com\r\n", isHeader: true},
+		}, {
+			name:   "encoded",
+			action: "insert{%0D%0A:end:value:2:encoded}",
+			want:   field{name: "Host", value: "example.com%0D%0A%0D%0A", isHeader: true},
+		}, {
+			name:   "raw plus",
+			action: "insert{a+b:end:value:1}",
+			want:   field{name: "Host", value: "example.coma+b", isHeader: true},
+		}, {
+			name:   "query plus",
+			action: "insert{a+b%2B:end:value:1:query}",
+			want:   field{name: "Host", value: "example.coma b+", isHeader: true},
+		}, {
+			name:   "replace raw plus",
+			action: "replace{a+b:value:1:raw}",
+			want:   field{name: "Host", value: "a+b", isHeader: true},
+		}, {
+			name:   "replace query plus",
+			action: "replace{a+b:value:1:query}",
+			want:   field{name: "Host", value: "a b", isHeader: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := newAction(tt.action, nil, nil)
+			assert.NoError(t, err)
+
+			got, err := a.apply(field{name: "Host", value: "example.com", isHeader: true})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got[0])
+
+			// the action must round-trip through its string representation.
+			b, err := newAction(a.string(), nil, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, a, b)
+		})
+	}
+}
+
+func TestAppendPrependAction(t *testing.T) {
+	tests := []struct {
+		action string
+		insert string
+	}{
+		{action: "append{%20:value:3}", insert: "insert{%20:end:value:3}"},
+		{action: "prepend{%0A:name}", insert: "insert{%0A:start:name:1}"},
+		{action: "append{%2F:value:2:encoded}", insert: "insert{%2F:end:value:2:encoded}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			a, err := newAction(tt.action, nil, nil)
+			assert.NoError(t, err)
+			ins, err := newAction(tt.insert, nil, nil)
+			assert.NoError(t, err)
+
+			fld := field{name: "Host", value: "example.com", isHeader: true}
+			want, err := ins.apply(fld)
+			assert.NoError(t, err)
+			got, err := a.apply(fld)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+
+			// the action must round-trip through its string representation and keep its name.
+			b, err := newAction(a.string(), nil, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, a, b)
+			assert.True(t, strings.HasPrefix(a.string(), tt.action[:strings.IndexByte(tt.action, '{')]))
+		})
+	}
+
+	for _, a := range []string{"append{a}", "prepend{a:header}", "append{a:value:x}", "prepend{a:value:1:base64}"} {
+		_, err := newAction(a, nil, nil)
+		assert.Error(t, err, a)
+	}
+}
+
+func TestNewAction_InvalidEncoding(t *testing.T) {
+	for _, a := range []string{"insert{a:end:value:1:path}", "replace{a:value:1:path}", "replace{%3:value:1:query}"} {
+		_, err := newAction(a, nil, nil)
+		assert.Error(t, err, a)
+	}
+}
+
 func TestReplaceAction_Apply(t *testing.T) {
 	type conf struct {
 		Value     string
@@ -158,8 +406,9 @@ func TestReplaceAction_Apply(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			a, _ := newReplaceAction(tt.conf.Value, tt.conf.Component, tt.conf.Num, nil)
-			got := a.apply(tt.field)
+			a, _ := newReplaceAction(tt.conf.Value, tt.conf.Component, tt.conf.Num, "", nil)
+			got, err := a.apply(tt.field)
+			assert.NoError(t, err)
 			assert.Equal(t, tt.want, got[0])
 		})
 	}
@@ -204,7 +453,8 @@ func TestDuplicateAction_Apply(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := newDuplicateAction(tt.actions.LeftAction, tt.actions.RightAction)
-			got := a.apply(tt.field)
+			got, err := a.apply(tt.field)
+			assert.NoError(t, err)
 			assert.Equal(t, tt.want, got)
 		})
 	}
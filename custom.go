@@ -0,0 +1,113 @@
+package algeneva
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ActionFunc transforms the value of a field. For headers, only the value is passed, not the name. An error returned
+// by ActionFunc stops the strategy from being applied and is returned by Apply.
+type ActionFunc func(value string) (string, error)
+
+// customActions holds the action factories registered with RegisterAction keyed by action name.
+var customActions = struct {
+	sync.RWMutex
+	factories map[string]func(args []string) (ActionFunc, error)
+}{factories: make(map[string]func(args []string) (ActionFunc, error))}
+
+// RegisterAction registers a custom action, name, that can be used in strategies like the built-in actions, e.g.
+// [HTTP:host:*]-name{arg1:arg2}-|. When a strategy containing the action is parsed, factory is called with the
+// arguments of the action, or nil if there are none, and returns the function that is applied to the value of the
+// field. An error returned by factory is returned by NewHTTPStrategy. A custom action can be followed by another
+// action, but, like every action other than duplicate, only has a left branch.
+//
+// An error is returned if name is the name of a built-in action, is already registered, or contains characters
+// that are part of the strategy syntax. It is safe to call RegisterAction concurrently with parsing strategies.
+func RegisterAction(name string, factory func(args []string) (ActionFunc, error)) error {
+	switch {
+	case name == "":
+		return errors.New("action name must not be empty")
+	case strings.ContainsAny(name, "[]{}(),:-|/%"):
+		return fmt.Errorf("invalid action name: %s", name)
+	}
+
+	switch name {
+	case "changecase", "insert", "append", "prepend", "replace", "duplicate":
+		return fmt.Errorf("cannot replace built-in action: %s", name)
+	}
+
+	customActions.Lock()
+	defer customActions.Unlock()
+	if _, ok := customActions.factories[name]; ok {
+		return fmt.Errorf("action already registered: %s", name)
+	}
+
+	customActions.factories[name] = factory
+	return nil
+}
+
+// newCustomAction returns a new customAction for the registered action name with arguments args and next action.
+// newCustomAction returns an error if name is not registered or if its factory returns an error.
+func newCustomAction(name string, args []string, next action) (*customAction, error) {
+	customActions.RLock()
+	factory, ok := customActions.factories[name]
+	customActions.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown action: %s", name)
+	}
+
+	fn, err := factory(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return &customAction{
+		name: name,
+		args: args,
+		fn:   fn,
+		next: terminateIfNil(next),
+	}, nil
+}
+
+// customAction applies an ActionFunc registered with RegisterAction to the value of the field.
+type customAction struct {
+	// name is the name the action was registered with.
+	name string
+	// args are the arguments the action was created with. args is nil if there are none.
+	args []string
+	// fn is the function returned by the factory of the action.
+	fn ActionFunc
+	// next is the next action in the action tree.
+	next action
+}
+
+// string returns a string representation of the custom action.
+func (a *customAction) string() string {
+	if a.args == nil {
+		return a.name + nextToString(a.next)
+	}
+
+	return fmt.Sprintf("%s{%s}%s", a.name, strings.Join(a.args, ":"), nextToString(a.next))
+}
+
+// apply applies fn to the value of the field and calls the next action in the action tree. An error returned by fn is
+// returned wrapped with the name of the action.
+func (a *customAction) apply(fld field) ([]field, error) {
+	value, err := a.fn(fld.value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", a.name, err)
+	}
+
+	fld.value = value
+	return a.next.apply(fld)
+}
+
+// unregisterAction removes the custom action registered under name, if any. It is used by tests to undo
+// RegisterAction.
+func unregisterAction(name string) {
+	customActions.Lock()
+	defer customActions.Unlock()
+	delete(customActions.factories, name)
+}
@@ -0,0 +1,68 @@
+package algeneva
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rot13(s string) (string, error) {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+13)%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+13)%26
+		}
+
+		return r
+	}, s), nil
+}
+
+func TestRegisterAction(t *testing.T) {
+	require.NoError(t, RegisterAction("rot13", func(args []string) (ActionFunc, error) {
+		if len(args) > 1 {
+			return nil, errors.New("rot13 takes at most 1 argument")
+		}
+
+		return rot13, nil
+	}))
+	t.Cleanup(func() { unregisterAction("rot13") })
+
+	s, err := NewHTTPStrategy("[HTTP:host:*]-rot13(insert{www.:start:value},)-|[HTTP:path:*]-rot13{x}-|")
+	require.NoError(t, err)
+
+	got, err := s.Apply([]byte("GET /some/path HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "GET /fbzr/cngu HTTP/1.1\r\nHost: www.rknzcyr.pbz\r\n\r\n", string(got))
+
+	// the custom action must round-trip through its string representation.
+	assert.Equal(t, "[HTTP:host:*]-rot13(insert{www.:start:value:1},)-|[HTTP:path:*]-rot13{x}-|", s.String())
+
+	_, err = NewHTTPStrategy("[HTTP:host:*]-rot13{a:b}-|")
+	assert.ErrorIs(t, err, ErrInvalidAction)
+
+	assert.Error(t, RegisterAction("rot13", nil), "already registered")
+	assert.Error(t, RegisterAction("insert", nil), "built-in")
+	assert.Error(t, RegisterAction("rot-13", nil), "syntax character")
+
+	_, err = NewHTTPStrategy("[HTTP:host:*]-rot26-|")
+	assert.ErrorIs(t, err, ErrInvalidAction)
+}
+
+func TestRegisterAction_Error(t *testing.T) {
+	errReject := errors.New("rejected")
+	require.NoError(t, RegisterAction("reject", func([]string) (ActionFunc, error) {
+		return func(string) (string, error) { return "", errReject }, nil
+	}))
+	t.Cleanup(func() { unregisterAction("reject") })
+
+	s, err := NewHTTPStrategy("[HTTP:host:*]-reject-|")
+	require.NoError(t, err)
+
+	_, err = s.Apply([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	assert.ErrorIs(t, err, errReject)
+}
@@ -0,0 +1,175 @@
+package algeneva
+
+import (
+	"fmt"
+	"slices"
+)
+
+// DiffStrategies parses strategies a and b and reports the differences between their rules. Rules are compared
+// using their parsed form, so formatting differences that do not change the meaning of a rule, such as the case of
+// the protocol or an omitted num argument, are ignored. Each difference is reported as one of the following:
+//
+//	removed rule <i>: <rule>
+//	added rule <j>: <rule>
+//	changed rule <i>: trigger <old> -> <new>
+//	changed rule <i>: action <old> -> <new>
+//
+// where i is the index of the rule in a and j is the index of the rule in b. An error is returned if either strategy
+// cannot be parsed.
+func DiffStrategies(a, b string) ([]string, error) {
+	sa, err := NewHTTPStrategy(a)
+	if err != nil {
+		return nil, fmt.Errorf("a: %w", err)
+	}
+
+	sb, err := NewHTTPStrategy(b)
+	if err != nil {
+		return nil, fmt.Errorf("b: %w", err)
+	}
+
+	ra, rb := sa.rules, sb.rules
+
+	// find the longest common subsequence of rules so that inserting or removing a rule does not cause every
+	// following rule to be reported as changed.
+	lcs := make([][]int, len(ra)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(rb)+1)
+	}
+
+	for i := len(ra) - 1; i >= 0; i-- {
+		for j := len(rb) - 1; j >= 0; j-- {
+			if ra[i].string() == rb[j].string() {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var (
+		diffs          []string
+		removed, added []int
+		i, j           int
+	)
+
+	// flush reports the rules removed and added since the last common rule. A removed and added rule at the same
+	// position that share a trigger or an action are reported as a single changed rule.
+	flush := func() {
+		n := 0
+		for ; n < len(removed) && n < len(added); n++ {
+			ri, rj := ra[removed[n]], rb[added[n]]
+			switch {
+			case ri.trigger.String() == rj.trigger.String():
+				diffs = append(diffs, fmt.Sprintf(
+					"changed rule %d: action %s -> %s", removed[n], ri.tree.string(), rj.tree.string(),
+				))
+			case ri.tree.string() == rj.tree.string():
+				diffs = append(diffs, fmt.Sprintf(
+					"changed rule %d: trigger %s -> %s", removed[n], ri.trigger.String(), rj.trigger.String(),
+				))
+			default:
+				diffs = append(diffs, fmt.Sprintf("removed rule %d: %s", removed[n], ri.string()))
+				diffs = append(diffs, fmt.Sprintf("added rule %d: %s", added[n], rj.string()))
+			}
+		}
+
+		for _, k := range removed[n:] {
+			diffs = append(diffs, fmt.Sprintf("removed rule %d: %s", k, ra[k].string()))
+		}
+
+		for _, k := range added[n:] {
+			diffs = append(diffs, fmt.Sprintf("added rule %d: %s", k, rb[k].string()))
+		}
+
+		removed, added = removed[:0], added[:0]
+	}
+
+	for i < len(ra) || j < len(rb) {
+		switch {
+		case i < len(ra) && j < len(rb) && ra[i].string() == rb[j].string():
+			flush()
+			i++
+			j++
+		case j == len(rb) || (i < len(ra) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, i)
+			i++
+		default:
+			added = append(added, j)
+			j++
+		}
+	}
+
+	flush()
+	return diffs, nil
+}
+
+// Equal reports whether s and other have the same rules. Rules are compared using their parsed form rather than their
+// string representation, so rules that only differ in formatting or in how a value is written, such as %41 and A, or
+// insert{a:end:value} and append{a:value}, are equal. Disabled rules must be disabled in both. Options such as
+// Atomic, StableRandom, and ConnectAuthority are not compared.
+func (s *HTTPStrategy) Equal(other *HTTPStrategy) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+
+	if len(s.rules) != len(other.rules) {
+		return false
+	}
+
+	for i, r := range s.rules {
+		o := other.rules[i]
+		if r.disabled != o.disabled || !triggersEqual(r.trigger, o.trigger) || !actionsEqual(r.tree, o.tree) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// triggersEqual reports whether triggers a and b match the same fields and values.
+func triggersEqual(a, b trigger) bool {
+	return a.proto == b.proto &&
+		a.targetField == b.targetField &&
+		a.matchStr == b.matchStr &&
+		a.absent == b.absent &&
+		a.joined == b.joined &&
+		a.decoded == b.decoded
+}
+
+// actionsEqual reports whether the action trees a and b are equal. Values are compared after they are decoded and
+// repeated num times, so the encoding and how the copies are written are ignored.
+func actionsEqual(a, b action) bool {
+	switch a := a.(type) {
+	case *changecaseAction:
+		b, ok := b.(*changecaseAction)
+		return ok && a.Case == b.Case && a.preserveEscapes == b.preserveEscapes && actionsEqual(a.next, b.next)
+	case *insertAction:
+		b, ok := b.(*insertAction)
+		return ok &&
+			a.value == b.value &&
+			a.variable == b.variable &&
+			(a.variable == "" || a.num == b.num) &&
+			a.location == b.location &&
+			a.component == b.component &&
+			actionsEqual(a.next, b.next)
+	case *replaceAction:
+		b, ok := b.(*replaceAction)
+		return ok &&
+			a.value == b.value &&
+			a.variable == b.variable &&
+			(a.variable == "" || a.num == b.num) &&
+			a.component == b.component &&
+			actionsEqual(a.next, b.next)
+	case *duplicateAction:
+		b, ok := b.(*duplicateAction)
+		return ok && actionsEqual(a.leftAction, b.leftAction) && actionsEqual(a.rightAction, b.rightAction)
+	case *customAction:
+		b, ok := b.(*customAction)
+		return ok && a.name == b.name && slices.Equal(a.args, b.args) && actionsEqual(a.next, b.next)
+	case *terminateAction:
+		_, ok := b.(*terminateAction)
+		return ok
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,150 @@
+package algeneva
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffStrategies(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       string
+		b       string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "equal",
+			a:    "[HTTP:host:*]-insert{%20:start:name:1}-|",
+			b:    "[http:host:*]-insert{%20:start:name}-|",
+		}, {
+			name: "added rule",
+			a:    "[HTTP:path:*]-insert{%20:start:value:1}-|",
+			b:    "[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:host:*]-duplicate(replace{/:name:64},)-|",
+			want: []string{"added rule 1: [HTTP:host:*]-duplicate(replace{/:name:64},)-|"},
+		}, {
+			name: "removed rule",
+			a:    "[HTTP:method:*]-changecase{lower}-|[HTTP:path:*]-insert{%20:start:value:1}-|",
+			b:    "[HTTP:path:*]-insert{%20:start:value:1}-|",
+			want: []string{"removed rule 0: [HTTP:method:*]-changecase{lower}-|"},
+		}, {
+			name: "changed action",
+			a:    "[HTTP:method:*]-changecase{lower}-|[HTTP:path:*]-insert{%20:start:value:1}-|",
+			b:    "[HTTP:method:*]-changecase{upper}-|[HTTP:path:*]-insert{%20:start:value:1}-|",
+			want: []string{"changed rule 0: action changecase{lower} -> changecase{upper}"},
+		}, {
+			name: "changed trigger",
+			a:    "[HTTP:method:*]-changecase{lower}-|",
+			b:    "[HTTP:version:*]-changecase{lower}-|",
+			want: []string{"changed rule 0: trigger [HTTP:method:*] -> [HTTP:version:*]"},
+		}, {
+			name:    "error: invalid strategy",
+			a:       "[HTTP:method:*]-changecase{lower}-|",
+			b:       "[HTTP:method:*]-changecase{lower}",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DiffStrategies(tt.a, tt.b)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHTTPStrategy_Equal(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want bool
+	}{
+		{
+			name: "same strategy",
+			a:    "[HTTP:host:*]-insert{%20:start:name:1}-|",
+			b:    "[HTTP:host:*]-insert{%20:start:name:1}-|",
+			want: true,
+		}, {
+			name: "different case and default num",
+			a:    "[http:HOST:*]-insert{a:end:value:1}-|",
+			b:    "[HTTP:host:*]-insert{a:end:value}-|",
+			want: true,
+		}, {
+			name: "comments",
+			a:    "/* lowercase the method */[HTTP:method:*]-changecase{lower}-|",
+			b:    "[HTTP:method:*]-changecase{lower}-|",
+			want: true,
+		}, {
+			name: "encoded value",
+			a:    "[HTTP:path:*]-replace{%41:value}-|",
+			b:    "[HTTP:path:*]-replace{A:value}-|",
+			want: true,
+		}, {
+			name: "append and insert at end",
+			a:    "[HTTP:host:*]-append{a:value:2}-|",
+			b:    "[HTTP:host:*]-insert{aa:end:value}-|",
+			want: true,
+		}, {
+			name: "nested duplicate",
+			a:    "[HTTP:host:*]-duplicate(replace{/:name:64},)-|",
+			b:    "[HTTP:host:*]-duplicate(replace{/:name:64},)-|",
+			want: true,
+		}, {
+			name: "different action",
+			a:    "[HTTP:method:*]-changecase{lower}-|",
+			b:    "[HTTP:method:*]-changecase{upper}-|",
+		}, {
+			name: "different trigger",
+			a:    "[HTTP:method:*]-changecase{lower}-|",
+			b:    "[HTTP:version:*]-changecase{lower}-|",
+		}, {
+			name: "different match string",
+			a:    "[HTTP:method:GET]-changecase{lower}-|",
+			b:    "[HTTP:method:POST]-changecase{lower}-|",
+		}, {
+			name: "different location",
+			a:    "[HTTP:host:*]-insert{a:end:value}-|",
+			b:    "[HTTP:host:*]-insert{a:start:value}-|",
+		}, {
+			name: "different duplicate branch",
+			a:    "[HTTP:host:*]-duplicate(replace{/:name:64},)-|",
+			b:    "[HTTP:host:*]-duplicate(,replace{/:name:64})-|",
+		}, {
+			name: "different number of rules",
+			a:    "[HTTP:method:*]-changecase{lower}-|",
+			b:    "[HTTP:method:*]-changecase{lower}-|[HTTP:path:*]-insert{%20:start:value:1}-|",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewHTTPStrategy(tt.a)
+			require.NoError(t, err)
+			b, err := NewHTTPStrategy(tt.b)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, a.Equal(b))
+			assert.Equal(t, tt.want, b.Equal(a))
+		})
+	}
+}
+
+func TestHTTPStrategy_EqualDisabled(t *testing.T) {
+	a, err := NewHTTPStrategy("[HTTP:method:*]-changecase{lower}-|")
+	require.NoError(t, err)
+	b, err := NewHTTPStrategy("[HTTP:method:*]-changecase{lower}-|")
+	require.NoError(t, err)
+
+	b.SetRuleEnabled(0, false)
+	assert.False(t, a.Equal(b))
+
+	a.SetRuleEnabled(0, false)
+	assert.True(t, a.Equal(b))
+}
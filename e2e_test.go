@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNormalizationAllStrategies(t *testing.T) {
@@ -23,3 +24,27 @@ func TestNormalizationAllStrategies(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalizationKazakhstanStrategies(t *testing.T) {
+	// These strategies replace the method entirely, so the original method cannot be recovered.
+	// The normalized request is still valid, but uses the default method instead.
+	irreversible := map[int]bool{
+		20: true, // [HTTP:method:*]-replace{%3A:value:1}-|
+		22: true, // [HTTP:method:*]-replace{HTTP/1.1:value:1}-|
+	}
+
+	for i, s := range Strategies["Kazakhstan"] {
+		t.Run(fmt.Sprintf("Kazakhstan[%d]", i), func(t *testing.T) {
+			results, pass, err := TestStrategyNormalization(s)
+			require.NoError(t, err)
+			assert.True(t, pass)
+			for _, r := range results {
+				if !assert.True(t, r.Pass, "%s: %s", r.Name, r.Msg) || irreversible[i] {
+					continue
+				}
+
+				assert.False(t, r.Irreversible, "%s: %s", r.Name, r.Msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package algeneva
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Field is a named part of a message that a strategy can be applied to with ApplyFields.
+type Field struct {
+	// Name is the name of the field. Triggers target a field by its name, case-insensitively, and actions applied to
+	// the name component modify it.
+	Name string
+	// Value is the value of the field. Actions applied to the value component modify it.
+	Value string
+}
+
+// ApplyFields applies the strategy to a message in any protocol that can be split into named fields, such as a custom
+// line-based protocol that resembles HTTP. split splits msg into its fields and join reassembles the modified fields
+// into a message. Errors from split and join are returned as is.
+//
+// Each field is treated like a header: a trigger targets the first field with a matching name, the name and value
+// components can be modified separately, duplicate actions add fields after the original, and a field whose name is
+// replaced with an empty string is removed. A joined trigger matches the values of every field with the name joined
+// with ", ", and triggers for absent headers never match. Target fields are only matched by name, so a trigger such as
+// [HTTP:path:*] or [HTTP:query.id:*] targets a field named path or query.id. The protocol in the triggers of the
+// strategy must still be HTTP. A variable in an action value, such as $host, expands to the value of the first field
+// with the same name, compared case-insensitively, or to an empty string if there is none.
+func (s *HTTPStrategy) ApplyFields(
+	msg []byte,
+	split func([]byte) ([]Field, error),
+	join func([]Field) ([]byte, error),
+) ([]byte, error) {
+	fields, err := split(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// i is the index of the field matched by the current rule.
+	var i int
+	match := func(r *rule) (field, bool) {
+		if i = r.trigger.matchFields(fields); i == -1 {
+			return field{}, false
+		}
+
+		return field{name: fields[i].Name, value: fields[i].Value, isHeader: true, vars: fieldVars(fields)}, true
+	}
+
+	// replace the field with the modified fields, dropping any whose name was deleted.
+	modify := func(_ field, mods []field) {
+		replaced := make([]Field, 0, len(mods))
+		for _, mod := range mods {
+			if mod.name != "" {
+				replaced = append(replaced, Field{Name: mod.name, Value: mod.value})
+			}
+		}
+
+		fields = append(fields[:i], append(replaced, fields[i+1:]...)...)
+	}
+
+	if err := s.applyRules(context.Background(), match, modify); err != nil {
+		return nil, err
+	}
+
+	return join(fields)
+}
+
+// ApplyParsed applies the strategy to a request that was already parsed into its method, path, version, and headers,
+// modifying them in place, so the request doesn't need to be serialized and parsed again. Nothing is modified if an
+// error is returned.
+//
+// Since a map has no order, the headers are ordered by name, as with http.Header.Write, with the values of each name
+// in the order they are in its slice. A header that is duplicated is added after the values already under its name.
+// Names are used exactly as the strategy leaves them, so they are not canonicalized, and a header whose name is
+// changed moves to the new name. A line break inserted by an action starts a new header, as it would on the wire; a
+// line without a colon is added as a name with an empty value.
+func (s *HTTPStrategy) ApplyParsed(method, path, version *string, headers map[string][]string) error {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		for _, value := range headers[name] {
+			lines = append(lines, name+": "+value)
+		}
+	}
+
+	req := &request{
+		method:  *method,
+		path:    *path,
+		version: *version,
+		headers: strings.Join(lines, "\r\n"),
+	}
+	if err := s.apply(context.Background(), req); err != nil {
+		return err
+	}
+
+	*method, *path, *version = req.method, req.path, req.version
+	for name := range headers {
+		delete(headers, name)
+	}
+
+	for _, line := range strings.Split(req.headers, "\r\n") {
+		if line == "" {
+			continue
+		}
+
+		name, value, _ := strings.Cut(line, ":")
+		headers[name] = append(headers[name], strings.Trim(value, " \t"))
+	}
+
+	return nil
+}
+
+// fieldVars returns the values of the variables that action values can reference in fields. Each variable is the
+// value of the first field with the same name. See actionVariables.
+func fieldVars(fields []Field) map[string]string {
+	vars := make(map[string]string, len(actionVariables))
+	for name := range actionVariables {
+		for _, f := range fields {
+			if strings.EqualFold(f.Name, name) {
+				vars[name] = f.Value
+				break
+			}
+		}
+	}
+
+	return vars
+}
+
+// matchFields returns the index of the first field in fields that the trigger matches, or -1 if there is none. If the
+// trigger is joined, the values of every field with the target name are matched, joined with ", ".
+func (t *trigger) matchFields(fields []Field) int {
+	if t.proto == "" || t.absent {
+		return -1
+	}
+
+	first := -1
+	var values []string
+	for i, f := range fields {
+		if !strings.EqualFold(f.Name, t.targetField) {
+			continue
+		}
+
+		if first == -1 {
+			first = i
+		}
+
+		if !t.joined {
+			break
+		}
+
+		values = append(values, f.Value)
+	}
+
+	if first == -1 {
+		return -1
+	}
+
+	value := fields[first].Value
+	if t.joined {
+		value = strings.Join(values, ", ")
+	}
+
+	if !t.matchValue(value) {
+		return -1
+	}
+
+	return first
+}
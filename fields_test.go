@@ -0,0 +1,178 @@
+package algeneva
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// splitLines splits a message made up of "<name> <value>" lines ending with "\n" into fields.
+func splitLines(msg []byte) ([]Field, error) {
+	var fields []Field
+	for _, line := range bytes.Split(bytes.TrimSuffix(msg, []byte("\n")), []byte("\n")) {
+		name, value, ok := bytes.Cut(line, []byte(" "))
+		if !ok {
+			return nil, fmt.Errorf("invalid line: %q", line)
+		}
+
+		fields = append(fields, Field{Name: string(name), Value: string(value)})
+	}
+
+	return fields, nil
+}
+
+// joinLines joins fields into a message made up of "<name> <value>" lines ending with "\n".
+func joinLines(fields []Field) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, f := range fields {
+		fmt.Fprintf(&buf, "%s %s\n", f.Name, f.Value)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func ExampleHTTPStrategy_ApplyFields() {
+	s, _ := NewHTTPStrategy("[HTTP:user:*]-changecase{upper}-|")
+	msg, _ := s.ApplyFields([]byte("USER alice\nPASS secret\n"), splitLines, joinLines)
+	fmt.Print(string(msg))
+	// Output:
+	// USER ALICE
+	// PASS secret
+}
+
+func TestHTTPStrategy_ApplyFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		msg      string
+		want     string
+	}{
+		{
+			name:     "no match",
+			strategy: "[HTTP:user:bob]-changecase{upper}-|",
+			msg:      "USER alice\nPASS secret\n",
+			want:     "USER alice\nPASS secret\n",
+		}, {
+			name:     "insert into name",
+			strategy: "[HTTP:pass:*]-insert{X:start:name}-|",
+			msg:      "USER alice\nPASS secret\n",
+			want:     "USER alice\nXPASS secret\n",
+		}, {
+			name:     "duplicate field",
+			strategy: "[HTTP:user:*]-duplicate(,replace{bob:value})-|",
+			msg:      "USER alice\nPASS secret\n",
+			want:     "USER alice\nUSER bob\nPASS secret\n",
+		}, {
+			name:     "remove field",
+			strategy: "[HTTP:pass:*]-replace{:name}-|",
+			msg:      "USER alice\nPASS secret\n",
+			want:     "USER alice\n",
+		}, {
+			name:     "variable from field",
+			strategy: "[HTTP:pass:*]-insert{$host:end:value}-|",
+			msg:      "USER alice\nPASS secret\nHOST example.com\n",
+			want:     "USER alice\nPASS secretexample.com\nHOST example.com\n",
+		}, {
+			name:     "variable without field",
+			strategy: "[HTTP:pass:*]-replace{$method:value}-|",
+			msg:      "USER alice\nPASS secret\n",
+			want:     "USER alice\nPASS \n",
+		}, {
+			name:     "joined fields",
+			strategy: "[HTTP:+tag:a, b]-changecase{upper}-|",
+			msg:      "TAG a\nUSER alice\nTAG b\n",
+			want:     "TAG A\nUSER alice\nTAG b\n",
+		}, {
+			name:     "joined fields don't match",
+			strategy: "[HTTP:+tag:a]-changecase{upper}-|",
+			msg:      "TAG a\nTAG b\n",
+			want:     "TAG a\nTAG b\n",
+		}, {
+			name:     "absent field never matches",
+			strategy: "[HTTP:!nick:*]-replace{a:value}-|",
+			msg:      "USER alice\n",
+			want:     "USER alice\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewHTTPStrategy(tt.strategy)
+			require.NoError(t, err)
+
+			got, err := s.ApplyFields([]byte(tt.msg), splitLines, joinLines)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestHTTPStrategy_ApplyFieldsError(t *testing.T) {
+	s, err := NewHTTPStrategy("[HTTP:user:*]-changecase{upper}-|")
+	require.NoError(t, err)
+
+	_, err = s.ApplyFields([]byte("USER\n"), splitLines, joinLines)
+	assert.Error(t, err)
+
+	errJoin := errors.New("join failed")
+	_, err = s.ApplyFields([]byte("USER alice\n"), splitLines, func([]Field) ([]byte, error) { return nil, errJoin })
+	assert.ErrorIs(t, err, errJoin)
+}
+
+func TestHTTPStrategy_ApplyParsed(t *testing.T) {
+	tests := []struct {
+		name        string
+		strategy    string
+		headers     map[string][]string
+		wantPath    string
+		wantHeaders map[string][]string
+	}{
+		{
+			name:        "change host",
+			strategy:    "[HTTP:host:*]-changecase{upper}-|",
+			headers:     map[string][]string{"Host": {"example.com"}, "Accept": {"*/*"}},
+			wantPath:    "/",
+			wantHeaders: map[string][]string{"HOST": {"EXAMPLE.COM"}, "Accept": {"*/*"}},
+		}, {
+			name:        "duplicate host",
+			strategy:    "[HTTP:host:*]-duplicate(,replace{a.com:value})-|",
+			headers:     map[string][]string{"Host": {"example.com"}},
+			wantPath:    "/",
+			wantHeaders: map[string][]string{"Host": {"example.com", "a.com"}},
+		}, {
+			name:        "rename host",
+			strategy:    "[HTTP:host:*]-replace{X%2DHost:name}-|",
+			headers:     map[string][]string{"Host": {"example.com"}},
+			wantPath:    "/",
+			wantHeaders: map[string][]string{"X-Host": {"example.com"}},
+		}, {
+			name:        "insert host into path",
+			strategy:    "[HTTP:path:*]-insert{$host:end:value}-|",
+			headers:     map[string][]string{"Host": {"example.com"}},
+			wantPath:    "/example.com",
+			wantHeaders: map[string][]string{"Host": {"example.com"}},
+		}, {
+			name:        "line break in value",
+			strategy:    "[HTTP:host:*]-insert{%0D%0AX:end:value}-|",
+			headers:     map[string][]string{"Host": {"example.com"}},
+			wantPath:    "/",
+			wantHeaders: map[string][]string{"Host": {"example.com"}, "X": {""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewHTTPStrategy(tt.strategy)
+			require.NoError(t, err)
+
+			method, path, version := "GET", "/", "HTTP/1.1"
+			require.NoError(t, s.ApplyParsed(&method, &path, &version, tt.headers))
+			assert.Equal(t, "GET", method)
+			assert.Equal(t, tt.wantPath, path)
+			assert.Equal(t, "HTTP/1.1", version)
+			assert.Equal(t, tt.wantHeaders, tt.headers)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package algeneva
+
+import "fmt"
+
+// maxSuggestedNum is the number of copies of a value above which insert and replace actions are reported by
+// ParseStrategy. Large values greatly inflate the size of the request.
+const maxSuggestedNum = 1024
+
+// Warning is a non-fatal problem found in a strategy by ParseStrategy.
+type Warning struct {
+	// Rule is the index of the rule the warning applies to.
+	Rule int
+	// Msg describes the problem.
+	Msg string
+}
+
+// String returns a string representation of the warning.
+func (w Warning) String() string {
+	return fmt.Sprintf("rule %d: %s", w.Rule, w.Msg)
+}
+
+// ParseStrategy parses strategystr into an HTTPStrategy and checks it for problems that do not prevent it from being
+// applied, but are likely mistakes, such as rules that do nothing or actions that repeat a value an excessive number
+// of times. These are returned as warnings. An error is only returned if strategystr cannot be parsed.
+func ParseStrategy(strategystr string) (HTTPStrategy, []Warning, error) {
+	s, err := NewHTTPStrategy(strategystr)
+	if err != nil {
+		return HTTPStrategy{}, nil, err
+	}
+
+	var warnings []Warning
+	for i, r := range s.rules {
+		for _, msg := range lintRule(r) {
+			warnings = append(warnings, Warning{Rule: i, Msg: msg})
+		}
+	}
+
+	return *s, warnings, nil
+}
+
+// lintRule returns a message for each problem found in r.
+func lintRule(r rule) []string {
+	if _, ok := r.tree.(*terminateAction); ok {
+		return []string{"rule has no actions and does not modify the request"}
+	}
+
+	// the name component only exists for headers. For other fields it is silently treated as the value.
+	nameIgnored := func(component string) bool {
+		return component == "name" && !r.trigger.targetsHeader()
+	}
+
+	var msgs []string
+	walkActions(r.tree, func(a action) {
+		switch a := a.(type) {
+		case *insertAction:
+			if a.num > maxSuggestedNum {
+				msgs = append(msgs, fmt.Sprintf("insert repeats %q %d times", a.Value, a.num))
+			}
+
+			if nameIgnored(a.component) {
+				msgs = append(msgs, fmt.Sprintf("insert into name of %s is applied to the value", r.trigger.targetField))
+			}
+		case *replaceAction:
+			if a.num > maxSuggestedNum {
+				msgs = append(msgs, fmt.Sprintf("replace repeats %q %d times", a.Value, a.num))
+			}
+
+			if nameIgnored(a.component) {
+				msgs = append(msgs, fmt.Sprintf("replace of name of %s is applied to the value", r.trigger.targetField))
+			}
+		}
+	})
+
+	return msgs
+}
+
+// walkActions calls fn for a and every action in the action tree below it, in depth-first order.
+func walkActions(a action, fn func(action)) {
+	fn(a)
+	switch a := a.(type) {
+	case *changecaseAction:
+		walkActions(a.next, fn)
+	case *insertAction:
+		walkActions(a.next, fn)
+	case *replaceAction:
+		walkActions(a.next, fn)
+	case *customAction:
+		walkActions(a.next, fn)
+	case *duplicateAction:
+		walkActions(a.leftAction, fn)
+		walkActions(a.rightAction, fn)
+	}
+}
@@ -0,0 +1,57 @@
+package algeneva
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		want     []Warning
+		wantErr  bool
+	}{
+		{
+			name:     "no warnings",
+			strategy: "[HTTP:host:*]-insert{%20:start:name:1}-|",
+		}, {
+			name:     "no-op rule",
+			strategy: "[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:host:*]--|",
+			want:     []Warning{{Rule: 1, Msg: "rule has no actions and does not modify the request"}},
+		}, {
+			name:     "large num",
+			strategy: "[HTTP:host:*]-duplicate(replace{a:name:64},insert{%20:end:value:4081})-|",
+			want:     []Warning{{Rule: 0, Msg: `insert repeats "%20" 4081 times`}},
+		}, {
+			name:     "name component of non-header field",
+			strategy: "[HTTP:method:*]-duplicate(,replace{a:name:1})-|[HTTP:path:*]-insert{%20:start:name:1}-|",
+			want: []Warning{
+				{Rule: 0, Msg: "replace of name of method is applied to the value"},
+				{Rule: 1, Msg: "insert into name of path is applied to the value"},
+			},
+		}, {
+			name:     "name component of header",
+			strategy: "[HTTP:host:*]-replace{a:name:1}-|",
+		}, {
+			name:     "error: invalid strategy",
+			strategy: "[HTTP:host:*]-insert{%20:start:name:1}",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, got, err := ParseStrategy(tt.strategy)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NotEmpty(t, s.rules)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -5,9 +5,14 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httputil"
 	"net/textproto"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 /*
@@ -36,8 +41,70 @@ import (
 // according to the RFCs.
 //
 // If a valid method or version cannot be found, then the method will default to GET or POST,
-// depending on if there is a body or not, and the version will default to HTTP/1.1.
+// depending on if there is a body or not, and the version will default to HTTP/1.1. A method that
+// was lowercased, such as "get", is recognized and uppercased. NormalizeRequest is the same as
+// NormalizeRequestWithOptions with DefaultNormalizeOptions; use NormalizeRequestWithOptions to
+// change these defaults.
 func NormalizeRequest(req []byte) ([]byte, error) {
+	return NormalizeRequestWithOptions(req, DefaultNormalizeOptions())
+}
+
+// DefaultNormalizeOptions returns the options NormalizeRequest uses, which infer the method from
+// the body and fold the case of the method. Options that only need to change a few of the
+// defaults should start from these rather than from the zero value, which turns them off.
+func DefaultNormalizeOptions() NormalizeOptions {
+	return NormalizeOptions{InferMethodFromBody: true, FoldMethodCase: true}
+}
+
+// NormalizeOptions configures how NormalizeRequestWithOptions normalizes a request.
+type NormalizeOptions struct {
+	// DefaultMethod is the method used if a valid method cannot be found. If empty, GET is used.
+	DefaultMethod string
+	// DefaultVersion is the version used if a valid version cannot be found. If empty, HTTP/1.1 is
+	// used.
+	DefaultVersion string
+	// InferMethodFromBody reports whether POST should be used instead of DefaultMethod if a valid
+	// method cannot be found and the request has a body. It is set by DefaultNormalizeOptions.
+	InferMethodFromBody bool
+	// DropChaffHeaders reports whether headers that were most likely injected by a strategy should
+	// be removed. This is a heuristic; currently a header is considered chaff if its name is a
+	// single repeated character, such as those produced by duplicating a header and replacing the
+	// name.
+	DropChaffHeaders bool
+	// AllowBareLF reports whether a bare LF, without a preceding CR, ends a header line. By
+	// default, only CRLF ends a header line and a bare LF is removed from the header as an invalid
+	// character. Some strategies use a bare LF to end a header line that a middlebox interprets
+	// differently than the server. Either way, the normalized request only uses CRLF.
+	AllowBareLF bool
+	// FoldMethodCase reports whether a method that is only valid once uppercased, such as "get"
+	// produced by changecase{lower}, should be recognized and uppercased. It is set by
+	// DefaultNormalizeOptions. Methods are case-sensitive, so without it such a method is not
+	// found and the default method is used.
+	FoldMethodCase bool
+	// KeepLastHost reports whether the last Host header should be kept instead of the first when
+	// there are duplicates. The kept value is placed where the first Host header was. Some
+	// strategies duplicate the Host header and modify the first copy, leaving the real host last.
+	KeepLastHost bool
+	// DecodeChunked reports whether a body with a Transfer-Encoding of only chunked should be
+	// decoded. The Transfer-Encoding header is replaced with a Content-Length header for the
+	// decoded body, and any trailers are dropped. An error is returned if the chunks are not
+	// framed correctly. Bodies with other transfer codings are left unchanged.
+	DecodeChunked bool
+	// JoinPath reports whether the components between the method and version should be joined
+	// into a single path when whitespace splits the path into more than one component and none of
+	// them is a valid path by itself, such as when a strategy inserts a space after the leading
+	// '/'. The whitespace between each component is recovered as %20, e.g. "/ home" becomes
+	// "/%20home". A component that is only slashes is not considered a valid path. If a component
+	// is a valid path, it is used and the rest are dropped, as they are by default, so junk split
+	// off after the path isn't added to it.
+	JoinPath bool
+}
+
+// NormalizeRequestWithOptions normalizes req like NormalizeRequest, but uses opts to determine how
+// it is normalized and the defaults for values that cannot be recovered. It is only the same as
+// NormalizeRequest if opts is DefaultNormalizeOptions; the zero value of NormalizeOptions turns
+// off the options that NormalizeRequest uses.
+func NormalizeRequestWithOptions(req []byte, opts NormalizeOptions) ([]byte, error) {
 	// Separate headers and body. The headers must end with "\r\n\r\n", even if body is empty.
 	idx := bytes.Index(req, []byte("\r\n\r\n"))
 	if idx == -1 {
@@ -48,16 +115,13 @@ func NormalizeRequest(req []byte) ([]byte, error) {
 	// line and not EOF. scanner.Scan will return false if it sees EOF.
 	head, body := req[:idx+2], req[idx+4:]
 
-	// parse and normalize request line and headers
-	scanner := bufio.NewScanner(bytes.NewReader(head))
-	scanner.Split(scanCRLF)
-	if !scanner.Scan() {
-		return nil, scanner.Err()
-	}
+	// parse and normalize request line. The request line always ends with CRLF, even if bare LFs
+	// are allowed to end header lines, since strategies insert LFs into the request line.
+	line, headerLines, _ := bytes.Cut(head, []byte("\r\n"))
 
 	// Even if parseRequestLine successfully parses the request line and err is nil, method and
 	// version could still be empty if they were not found.
-	method, path, version, err := parseRequestLine(scanner.Bytes())
+	method, path, version, err := parseRequestLine(line, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -75,9 +139,12 @@ func NormalizeRequest(req []byte) ([]byte, error) {
 	//
 	// For now, we will use the second strategy since it is easier to implement.
 	if method == "" {
-		if len(body) > 0 {
+		switch {
+		case opts.InferMethodFromBody && len(body) > 0:
 			method = "POST"
-		} else {
+		case opts.DefaultMethod != "":
+			method = opts.DefaultMethod
+		default:
 			method = "GET"
 		}
 	}
@@ -85,32 +152,113 @@ func NormalizeRequest(req []byte) ([]byte, error) {
 	// We also need to check version for the same reason. Since Geneva only supports HTTP/1.0 and
 	// HTTP/1.1, we will use HTTP/1.1 as the default.
 	if version == "" {
-		version = "HTTP/1.1"
+		version = opts.DefaultVersion
+		if version == "" {
+			version = "HTTP/1.1"
+		}
 	}
 
 	// Now clean the headers. We're only going to clean the headers, we'll leave validating them to
 	// the caller.
+	scanner := bufio.NewScanner(bytes.NewReader(headerLines))
+	// Strategies can inflate a header line well past the default maximum token size of the
+	// scanner. The headers are already in memory, so no line can be longer than all of them.
+	scanner.Buffer(nil, len(headerLines)+1)
+	if opts.AllowBareLF {
+		// bufio.ScanLines splits on LF and drops a trailing CR, so it handles both.
+		scanner.Split(bufio.ScanLines)
+	} else {
+		scanner.Split(scanCRLF)
+	}
+
 	var headers [][]byte
+	var fragment []byte
 	hostFnd := false
+	hostIdx := 0
+	// prev is the index in headers of the header on the previous line, or -1 if it was dropped.
+	prev := -1
 	for scanner.Scan() {
 		h := scanner.Bytes()
-		h = append([]byte{}, h...) // Make a copy of h so scanner.Scan doesn't overwrite it.
+
+		// A line that starts with whitespace and has no colon continues the value of the header
+		// on the previous line (obs-fold). As allowed by RFC 7230, section 3.2.4, we replace the
+		// line break with a space, or nothing for the host since it can't contain spaces. If the
+		// previous header was dropped, the rest of its value is dropped as well.
+		if fragment == nil && len(h) > 0 && (h[0] == ' ' || h[0] == '\t') &&
+			bytes.IndexByte(h, ':') == -1 && len(headers) > 0 {
+			if prev != -1 {
+				headers[prev] = foldHeader(headers[prev], h, true)
+			}
+
+			continue
+		}
+
+		// A strategy can insert a CRLF, or a bare LF if AllowBareLF is set, into a header, e.g.
+		// insert{%0A:random:name}, which splits it across two lines. If the line break was
+		// inserted into the name, the previous line is the start of the name, so we join it with
+		// this line to recover the original header. Otherwise, the previous line is the rest of
+		// the value of the header before it.
+		if fragment != nil {
+			if isSplitName(fragment, h) {
+				h = append(fragment, h...)
+			} else {
+				if len(headers) == 0 {
+					return nil, fmt.Errorf("invalid header: %q", fragment)
+				}
+
+				if prev != -1 {
+					headers[prev] = foldHeader(headers[prev], fragment, false)
+				}
+
+				h = append([]byte{}, h...) // Make a copy of h so scanner.Scan doesn't overwrite it.
+			}
+
+			fragment = nil
+		} else {
+			h = append([]byte{}, h...) // Make a copy of h so scanner.Scan doesn't overwrite it.
+		}
+
+		// A line without a colon is either the start of a split name or the rest of a split value,
+		// which we can only tell apart once we see the next line.
+		if bytes.IndexByte(h, ':') == -1 {
+			fragment = h
+			continue
+		}
 
 		h, err := cleanHeader(h)
 		if err != nil {
 			return nil, fmt.Errorf("%w: %s", err, h)
 		}
 
+		// A strategy can replace the name of a header with only invalid characters, such as '/' or
+		// '×'. Since there's no way to know what the name was, and the header is invalid without
+		// one, we drop it.
+		prev = -1
+		if h[0] == ':' {
+			continue
+		}
+
+		if opts.DropChaffHeaders && isChaffHeader(h) {
+			continue
+		}
+
 		// Since there can only be one host header, we need to check if it was already found. We
-		// keep the first one we find and ignore the rest.
+		// keep the first one we find and ignore the rest, unless the last one should be kept.
 		if bytes.HasPrefix(h, []byte("Host:")) {
 			if hostFnd {
+				if opts.KeepLastHost {
+					headers[hostIdx] = h
+					prev = hostIdx
+				}
+
 				continue
 			}
 
 			hostFnd = true
+			hostIdx = len(headers)
 		}
 
+		prev = len(headers)
 		headers = append(headers, h)
 	}
 
@@ -118,25 +266,141 @@ func NormalizeRequest(req []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	// Now we need to rebuild the request. req might not be big enough to hold the new request, so
-	// we need to create a new buffer.
-	rl := []byte(method + " " + path + " " + version)
-	headers = append([][]byte{rl}, headers...)
-	newHead := bytes.Join(headers, []byte("\r\n"))
+	// There's no line left to join a fragment at the end of the headers with, so it can only be
+	// the rest of the value of the last header.
+	if fragment != nil {
+		if len(headers) == 0 {
+			return nil, fmt.Errorf("invalid header: %q", fragment)
+		}
+
+		if prev != -1 {
+			headers[prev] = foldHeader(headers[prev], fragment, false)
+		}
+	}
+
+	r := &request{
+		method:  method,
+		path:    path,
+		version: version,
+		headers: string(bytes.Join(headers, []byte("\r\n"))),
+		body:    body,
+	}
+
+	if opts.DecodeChunked && isChunked(headers) {
+		if err := decodeChunked(r); err != nil {
+			return nil, err
+		}
+	}
+
+	// HTTP/1.1 requires a Host header. If a strategy removed it, we can only recover it when the
+	// request-target is in absolute-form, in which case the authority is the host (RFC 7230,
+	// section 5.4).
+	if !hostFnd {
+		if host := hostFromAbsoluteForm(path); host != "" {
+			r.addHeader("Host", host)
+		}
+	}
+
+	// req might not be big enough to hold the new request, so bytes creates a new buffer.
+	return r.bytes(), nil
+}
+
+// CleanRequest removes invalid characters and whitespace from the request line and headers of req
+// without inferring missing values. Unlike NormalizeRequest, the method and version are not
+// replaced with defaults, so an error is returned if either cannot be found. Headers are only
+// cleaned; duplicate Host headers are kept and a missing one is not added. Headers whose name is
+// made up of only invalid characters are removed since they cannot be cleaned into a valid header.
+func CleanRequest(req []byte) ([]byte, error) {
+	idx := bytes.Index(req, []byte("\r\n\r\n"))
+	if idx == -1 {
+		return nil, errors.New("missing header/body separator")
+	}
+
+	line, headerLines, _ := bytes.Cut(req[:idx], []byte("\r\n"))
+	method, path, version, err := parseRequestLine(line, NormalizeOptions{})
+	switch {
+	case err != nil:
+		return nil, err
+	case method == "":
+		return nil, fmt.Errorf("invalid method: %q", line)
+	case version == "":
+		return nil, fmt.Errorf("invalid version: %q", line)
+	}
+
+	var headers []string
+	for len(headerLines) > 0 {
+		var h []byte
+		h, headerLines, _ = bytes.Cut(headerLines, []byte("\r\n"))
 
-	newReq := make([]byte, len(newHead)+4+len(body))
+		// cleanHeader reuses h, so we need to copy it so req isn't modified.
+		h, err := cleanHeader(append([]byte{}, h...))
+		if err != nil {
+			// cleanHeader already includes the header in the error.
+			return nil, err
+		}
+
+		if h[0] != ':' {
+			headers = append(headers, string(h))
+		}
+	}
+
+	r := &request{
+		method:  method,
+		path:    path,
+		version: version,
+		headers: strings.Join(headers, "\r\n"),
+		body:    req[idx+4:],
+	}
+
+	return r.bytes(), nil
+}
+
+// isChunked returns true if the Transfer-Encoding of the cleaned headers is only chunked.
+func isChunked(headers [][]byte) bool {
+	for _, h := range headers {
+		if value, ok := bytes.CutPrefix(h, []byte("Transfer-Encoding:")); ok {
+			return bytes.EqualFold(bytes.TrimSpace(value), []byte("chunked"))
+		}
+	}
+
+	return false
+}
+
+// decodeChunked decodes the chunked body of r and replaces the Transfer-Encoding header with a
+// Content-Length header for the decoded body. A request with both Transfer-Encoding and
+// Content-Length is invalid, so an existing Content-Length header is set to the decoded length.
+func decodeChunked(r *request) error {
+	decoded, err := io.ReadAll(httputil.NewChunkedReader(bytes.NewReader(r.body)))
+	if err != nil {
+		return fmt.Errorf("invalid chunked body: %w", err)
+	}
+
+	for h := r.getHeader("Transfer-Encoding"); h != ""; h = r.getHeader("Transfer-Encoding") {
+		r.removeHeader(h)
+	}
+
+	r.setHeader("Content-Length", strconv.Itoa(len(decoded)))
+	r.body = decoded
+	return nil
+}
+
+// isChaffHeader returns true if the name of header h is a single repeated character, ignoring
+// case, which is almost certainly not a real header.
+func isChaffHeader(h []byte) bool {
+	name, _, _ := bytes.Cut(h, []byte(":"))
+	if len(name) == 0 {
+		return false
+	}
 
-	copy(newReq, []byte(newHead))
-	copy(newReq[len(newHead):], []byte("\r\n\r\n"))
-	copy(newReq[len(newHead)+4:], body)
-	return newReq, nil
+	name = bytes.ToLower(name)
+	return len(bytes.Trim(name, string(name[:1]))) == 0
 }
 
 // parseRequestLine tries to parse and normalize an HTTP request line. parseRequestLine adheres
 // loosely to the RFC spec for HTTP/1.0 and HTTP/1.1. If no valid method or version is found, then
-// the empty string is returned. An error is returned if there are less than three components after
-// removing excess whitespace.
-func parseRequestLine(line []byte) (method, path, version string, err error) {
+// the empty string is returned. Only the FoldMethodCase and JoinPath options of opts are used. An
+// error is returned if there are less than three components after removing excess whitespace.
+func parseRequestLine(line []byte, opts NormalizeOptions) (method, path, version string, err error) {
 	// We need to parse out each component, which is separated by at least one SP and zero or more
 	// OWS. (The spec is more strict than this now, but some servers are not which is why Geneva
 	// supports it.)
@@ -149,34 +413,30 @@ func parseRequestLine(line []byte) (method, path, version string, err error) {
 	// We'll also need to clean each component; and since components could be duplicated with
 	// modifications or whitespace inserted in the middle, there could be more than 3 (which we'll
 	// have to try to filter out later).
-	// One way to do this:
-	//    | trim leading OWS
-	//    | parse upto first SP
-	//    | remove trailing OWS
-	//    | repeat until there are no more SPs
 	//
-	//    | finally find and clean each component
-
-	var components [][]byte
-	for len(line) > 0 {
-		line = bytes.TrimSpace(line)
-		sp := bytes.IndexByte(line, ' ')
-		if sp == -1 {
-			sp = len(line)
-		}
-
-		comp := bytes.TrimSpace(line[:sp])
-		if len(comp) > 0 {
-			components = append(components, comp)
-		}
-
-		line = line[sp:]
+	// splitTokens splits the line at each SP or HTAB and then trims any other whitespace from each
+	// component, without re-trimming the rest of the line for every component.
+	components := splitTokens(line)
+
+	// A strategy can replace the method with only whitespace or control characters, e.g.
+	// replace{%0A:value:4336}, which leaves only the path and version. If that's what's left in
+	// front of the first component and it isn't a method, we add an empty one so that the method
+	// is inferred like any other invalid method. A line that simply has no method is still an
+	// error.
+	if len(components) == 2 && len(line) > 0 && (isCtrl(line[0]) || line[0] == ' ') &&
+		!isValidMethod(strings.ToUpper(string(clean(components[0], isAlpha)))) {
+		components = [][]byte{nil, components[0], components[1]}
 	}
 
 	if len(components) < 3 {
 		return "", "", "", fmt.Errorf("request line has less than 3 components: %q", line)
 	}
 
+	// A strategy could duplicate the entire request line, in which case finding the first method
+	// and the last version could span two request lines. So we check for this first and only
+	// keep the first request line if so.
+	components = firstRequestLine(components)
+
 	// If we have 3 or more components, then we need to clean each component and, if more than 3,
 	// try to figure out which component is which. The easiest way to do this is to find the method
 	// and version first, as the path must be between them.
@@ -186,7 +446,13 @@ func parseRequestLine(line []byte) (method, path, version string, err error) {
 	// Attempt to find method
 	for ; mIdx < len(components)-2; mIdx++ {
 		c := clean(components[mIdx], isAlpha)
-		m := string(c)
+		if opts.FoldMethodCase {
+			c = bytes.ToUpper(c)
+		}
+
+		// The method could have been duplicated without a separator, e.g. GETGET, so we check for
+		// a repeated method as well.
+		m := string(unrepeat(c))
 		if isValidMethod(m) {
 			method = m
 			break
@@ -200,7 +466,7 @@ func parseRequestLine(line []byte) (method, path, version string, err error) {
 
 	// Attempt to find version
 	for vIdx = len(components) - 1; vIdx >= 2; vIdx-- {
-		c := clean(components[vIdx], func(b byte) bool { return isValidToken(b, versionTokens) })
+		c := clean(components[vIdx], func(b byte) bool { return isValidToken(b, &versionTokens) })
 		v := string(c)
 		if isVersion1x(v) {
 			version = v
@@ -217,22 +483,133 @@ func parseRequestLine(line []byte) (method, path, version string, err error) {
 	// characters were inserted in front of path if in the origin or absolute form or inserted at
 	// the front or end of the path if in the asterisk form.
 	path = findPath(components[mIdx+1 : vIdx])
+	if opts.JoinPath && vIdx-mIdx > 2 {
+		if joined := joinPath(components[mIdx+1 : vIdx]); joined != "" {
+			path = joined
+		}
+	}
 
-	if path == "" {
-		// We still didn't find a valid path so it must have been overridden by the replace action.
-		// There's no way to know what the original path was so we'll set it to the root.
+	if strings.Trim(path, "/") == "" {
+		// We still didn't find a valid path, or the path is only slashes, so it must have been
+		// overridden by the replace action. There's no way to know what the original path was so
+		// we'll set it to the root.
 		path = "/"
 	}
 
 	return method, path, version, nil
 }
 
+// joinPath joins comps into a single path, percent-encoding the whitespace that separated them as
+// %20, if none of comps is a valid path by itself. Otherwise, or if the joined components don't
+// contain a path either, joinPath returns the empty string. A component that is only slashes is
+// not considered a valid path since it is what remains when whitespace is inserted after the
+// leading '/'.
+func joinPath(comps [][]byte) string {
+	for _, c := range comps {
+		if isValidPath(c) && len(bytes.Trim(c, "/")) > 0 {
+			return ""
+		}
+	}
+
+	return findPath([][]byte{bytes.Join(comps, []byte("%20"))})
+}
+
+// splitTokens splits line into the components of a request line, which are separated by one or
+// more SP or HTAB. Any other whitespace, such as CR, is trimmed from the start and end of each
+// component, and components that are empty after trimming are dropped. The components are
+// subslices of line, so nothing is copied.
+func splitTokens(line []byte) [][]byte {
+	isSep := func(b byte) bool { return b == ' ' || b == '\t' }
+
+	// count the components first so the slice only needs to be allocated once, even if a
+	// strategy split the line into thousands of components.
+	var n int
+	for i, b := range line {
+		if !isSep(b) && (i == 0 || isSep(line[i-1])) {
+			n++
+		}
+	}
+
+	components := make([][]byte, 0, n)
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		if i < len(line) && !isSep(line[i]) {
+			if start == -1 {
+				start = i
+			}
+
+			continue
+		}
+
+		if start != -1 {
+			if comp := bytes.TrimSpace(line[start:i]); len(comp) > 0 {
+				components = append(components, comp)
+			}
+
+			start = -1
+		}
+	}
+
+	return components
+}
+
+// unrepeat returns the shortest prefix of b that b is made up of repeats of. If b is not a
+// repeated sequence, b is returned.
+func unrepeat(b []byte) []byte {
+	for n := 1; n <= len(b)/2; n++ {
+		if len(b)%n == 0 && isRepeatOf(b, n) {
+			return b[:n]
+		}
+	}
+
+	return b
+}
+
+// isRepeatOf reports whether b is made up of repeats of its first n bytes, without building the
+// repeated sequence to compare against.
+func isRepeatOf(b []byte, n int) bool {
+	for i := n; i < len(b); i++ {
+		if b[i] != b[i-n] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// firstRequestLine returns the components of one request line if components consists of repeated
+// request lines, each with a valid method and version. The first copy whose path is valid is
+// used, since the path of the other copies might have been modified, or the first copy if none
+// is. Otherwise, components is returned unchanged.
+func firstRequestLine(components [][]byte) [][]byte {
+	n := len(components)
+	if n < 6 || n%3 != 0 {
+		return components
+	}
+
+	for i := 0; i < n; i += 3 {
+		m := clean(components[i], isAlpha)
+		v := clean(components[i+2], func(b byte) bool { return isValidToken(b, &versionTokens) })
+		if !isValidMethod(string(m)) || !isVersion1x(string(v)) {
+			return components
+		}
+	}
+
+	for i := 0; i < n; i += 3 {
+		if isValidPath(clean(components[i+1], isValidPathToken)) {
+			return components[i : i+3]
+		}
+	}
+
+	return components[:3]
+}
+
 func findPath(components [][]byte) (path string) {
-	cleanedComps := make([][]byte, 0, len(components))
+	// cleanedComps is only needed if no component is a valid path, which is uncommon, so it isn't
+	// preallocated.
+	var cleanedComps [][]byte
 	for _, comp := range components {
-		comp = clean(comp, func(b byte) bool {
-			return isValidToken(b, validTokenTable) || b == '/' || b == ':'
-		})
+		comp = clean(comp, isValidPathToken)
 		if isValidPath(comp) {
 			// comp matches the origin, absolute, or asterisk form so we assume it's the path and
 			// return it.
@@ -254,23 +631,26 @@ func findPath(components [][]byte) (path string) {
 		// where the correct path actually starts if multiple forms are present, e.g. '/*', so we
 		// just return the first one we find.
 
-		// First check for 'http(s)://' since '/' is a valid path form.
-		// BUG: There is an edge case where 'http(s)://' or 'http(s)://<url w/o top-level domain>
-		// is inserted at the beginning of the path and the path is not an absolute form. This will
-		// cause an invalid path to be returned.
-		comp = bytes.ToLower(comp)
-		idx := bytes.Index(comp, []byte("http"))
+		// First check for 'http(s)://' since '/' is a valid path form. If 'http(s)://' is directly
+		// followed by '/', then it was inserted in front of an origin-form path, so we strip it.
+		idx := bytes.Index(bytes.ToLower(comp), []byte("http"))
 		if idx != -1 {
-			j := 4
-			if comp[idx+4] == 's' {
-				j++
-			}
+			if rest, ok := cutScheme(comp[idx:]); ok && len(rest) > 0 {
+				if rest[0] == '/' {
+					return string(rest)
+				}
 
-			if bytes.HasPrefix(comp[idx+j:], []byte("://")) {
 				return string(comp[idx:])
 			}
 		}
 
+		// The scheme might have been modified or replaced, e.g. httpx://example.com. The authority
+		// is still intact, so we rebuild the absolute-form with the default scheme. The scheme can't
+		// contain any of the path delimiters, otherwise "://" is part of the path, e.g. in the query.
+		if _, authority, rest, ok := splitAbsoluteForm(string(comp)); ok && authority != "" {
+			return "http://" + authority + rest
+		}
+
 		// Now check for '/'
 		idx = bytes.IndexByte(comp, '/')
 		if idx != -1 {
@@ -286,8 +666,23 @@ func findPath(components [][]byte) (path string) {
 	return ""
 }
 
-// isValidMethod returns true if method is a valid HTTP method.
-func isValidMethod(method string) bool {
+// hostFromAbsoluteForm returns the authority of path if path is in absolute-form. Otherwise, it
+// returns the empty string.
+func hostFromAbsoluteForm(path string) string {
+	u, err := url.Parse(path)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+
+	return u.Host
+}
+
+// validMethods is the set of methods recognized during normalization. Methods can be added with
+// AddMethod.
+var validMethods = struct {
+	sync.RWMutex
+	methods map[string]bool
+}{methods: map[string]bool{
 	// RFC 7231, section 4.1
 	//    method    = "GET"          ; section 4.3.1
 	//              | "HEAD"         ; section 4.3.2
@@ -297,13 +692,28 @@ func isValidMethod(method string) bool {
 	//              | "CONNECT"      ; section 4.3.6
 	//              | "OPTIONS"      ; section 4.3.7
 	//              | "TRACE"        ; section 4.3.8
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "DELETE": true, "CONNECT": true,
+	"OPTIONS": true, "TRACE": true,
+
+	// RFC 5789
+	"PATCH": true,
+}}
+
+// AddMethod adds method to the methods that are recognized when normalizing a request, such as the
+// WebDAV method PROPFIND. Methods are case-sensitive. Since invalid characters are removed from the
+// method before it is checked, only methods made up of letters can be recognized. AddMethod is safe
+// to call concurrently with normalization.
+func AddMethod(method string) {
+	validMethods.Lock()
+	defer validMethods.Unlock()
+	validMethods.methods[method] = true
+}
 
-	switch method {
-	case "GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE":
-		return true
-	}
-
-	return false
+// isValidMethod returns true if method is a valid HTTP method.
+func isValidMethod(method string) bool {
+	validMethods.RLock()
+	defer validMethods.RUnlock()
+	return validMethods.methods[method]
 }
 
 // isValidPath returns true if p is a valid HTTP request path. isValidPath does not check for
@@ -319,16 +729,31 @@ func isValidPath(p []byte) bool {
 	// can add support for it.
 
 	switch {
+	case len(p) == 0:
+		return false
 	case p[0] == '/': // origin-form
 		return true
-	case len(p) > 8 && (p[0] == 'H' || p[0] == 'h'): // absolute-form
-		p0 := bytes.ToLower(p[:8])
-		return bytes.Equal(p0, []byte("http://")) || bytes.Equal(p0, []byte("https://"))
+	case p[0] == 'H' || p[0] == 'h': // absolute-form
+		// 'http(s)://' followed by '/' is not a valid absolute-form since it has no authority.
+		rest, ok := cutScheme(p)
+		return ok && len(rest) > 0 && rest[0] != '/'
 	}
 
 	return bytes.Equal(p, []byte("*")) // asterisk-form
 }
 
+// cutScheme returns p without the leading 'http://' or 'https://', and reports whether either was
+// found. The scheme is matched case-insensitively.
+func cutScheme(p []byte) ([]byte, bool) {
+	for _, scheme := range [][]byte{[]byte("http://"), []byte("https://")} {
+		if len(p) >= len(scheme) && bytes.EqualFold(p[:len(scheme)], scheme) {
+			return p[len(scheme):], true
+		}
+	}
+
+	return p, false
+}
+
 // isVersion1x returns true if version is HTTP/1.0 or HTTP/1.1.
 func isVersion1x(v string) bool {
 	switch v {
@@ -342,8 +767,21 @@ func isVersion1x(v string) bool {
 // clean returns s with all invalid characters removed. clean uses validTokenFn to determine if a
 // character is valid.
 func clean(s []byte, validTokenFn func(b byte) bool) []byte {
-	var cleaned []byte
-	for _, b := range s {
+	// Most components are already clean, so we only copy s once we find an invalid character,
+	// and then allocate only once, even if a strategy inflated s to thousands of characters.
+	i := 0
+	for i < len(s) && validTokenFn(s[i]) {
+		i++
+	}
+
+	if i == len(s) {
+		// Limit the capacity so appending to the result can't overwrite what follows s.
+		return s[:i:i]
+	}
+
+	cleaned := make([]byte, i, len(s)-1)
+	copy(cleaned, s[:i])
+	for _, b := range s[i+1:] {
 		if validTokenFn(b) {
 			cleaned = append(cleaned, b)
 		}
@@ -356,7 +794,7 @@ func clean(s []byte, validTokenFn func(b byte) bool) []byte {
 //
 // Note that obs-fold (line folding) is not supported, even though it is still currently in the
 // spec, as it is obsolete.
-var validTokenTable = [127]bool{
+var validTokenTable = [256]bool{
 	// RFC 7230, section 3.2
 	//    header-field   = field-name ":" OWS field-value OWS
 	//    field-name     = token
@@ -391,9 +829,10 @@ var validTokenTable = [127]bool{
 	'-': true, '.': true, '|': true, '~': true, '^': true, '_': true, '`': true,
 }
 
-// isValidToken returns true if b is a valid token. tokenTable is a table of ASCII values.
-func isValidToken(b byte, tokenTable [127]bool) bool {
-	return b < 127 && tokenTable[b]
+// isValidToken returns true if b is a valid token. tokenTable covers every byte value so b can
+// be used as an index directly; DEL and bytes above 127 are never valid tokens.
+func isValidToken(b byte, tokenTable *[256]bool) bool {
+	return tokenTable[b]
 }
 
 func isCtrl(b byte) bool {
@@ -404,19 +843,39 @@ func isAlpha(b byte) bool {
 	return (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')
 }
 
-var versionTokens = [127]bool{
+var versionTokens = [256]bool{
 	'H': true, 'T': true, 'P': true, 'h': true, 't': true, 'p': true,
 	'/': true, '1': true, '.': true, '0': true,
 }
 
+// isValidPathToken returns true if b is valid in a request-target. In addition to the token
+// characters, this includes the delimiters used in a URI so that the query is preserved.
+func isValidPathToken(b byte) bool {
+	// RFC 3986, section 3
+	//    gen-delims  = ":" / "/" / "?" / "#" / "[" / "]" / "@"
+	//    sub-delims  = "!" / "$" / "&" / "'" / "(" / ")" / "*" / "+" / "," / ";" / "="
+	//
+	// '#' and the remaining sub-delims are already token characters. '[' and ']' are only used for
+	// IPv6 hosts in the absolute-form.
+	switch b {
+	case ':', '/', '?', '[', ']', '@', '(', ')', ',', ';', '=':
+		return true
+	}
+
+	return isValidToken(b, &validTokenTable)
+}
+
 // hostTokenTable is a table of valid tokens for host header.
-var hostTokenTable = [127]bool{
+var hostTokenTable = [256]bool{
 	// RFC 3986, section 3.2.2
 	//
 	// This lets us efficiently check for valid host characters. Plus, it's easier to read than
 	// comparing ascii values with <, >, ==.
 	// Some characters that are valid in other header values are not valid in the host header value,
 	// which is why we have a separate table.
+	//
+	// Bytes above 127 are not valid. An internationalized host is sent in its ASCII form (punycode),
+	// so raw UTF-8 in a host was almost certainly inserted by a strategy, such as %C2%B0.
 
 	'0': true, '1': true, '2': true, '3': true, '4': true, '5': true, '6': true, '7': true,
 	'8': true, '9': true,
@@ -434,6 +893,9 @@ var hostTokenTable = [127]bool{
 	// host port delim
 	':': true,
 
+	// IP-literal, e.g. [::1]
+	'[': true, ']': true,
+
 	// sub-delims
 	'!': true, '$': true, '&': true, '\'': true, '(': true, ')': true, '*': true, '+': true,
 	',': true, ';': true, '=': true,
@@ -482,15 +944,15 @@ func cleanHeader(h []byte) ([]byte, error) {
 	// With the exception of the host header, we can clean both the name and value with the
 	// validTokenTable (RFC 7230, section 3.2). The host header value has a different set of valid
 	// characters (RFC 3986, section 3.2.2) so we'll use hostTokenTable for that.
-	name = clean(name, func(b byte) bool { return isValidToken(b, validTokenTable) })
-	hasSepOSP := value[0] == ' '
+	name = clean(name, func(b byte) bool { return isValidToken(b, &validTokenTable) })
+	hasSepOSP := len(value) > 0 && value[0] == ' '
 	if hasSepOSP {
 		value = value[1:]
 	}
 
 	cname := textproto.CanonicalMIMEHeaderKey(string(name))
 	if cname == "Host" {
-		value = clean(value, func(b byte) bool { return isValidToken(b, hostTokenTable) })
+		value = clean(value, func(b byte) bool { return isValidToken(b, &hostTokenTable) })
 	} else {
 		value = bytes.TrimSpace(value)
 		value = clean(value, validHeaderValueToken)
@@ -509,6 +971,52 @@ func cleanHeader(h []byte) ([]byte, error) {
 	return h[:n], nil
 }
 
+// foldHeader appends the continuation line cont of a folded or split header to the cleaned header
+// h, in place of the line break between them. If space is true, the continuation is separated by a
+// space, except for the host since it can't contain spaces.
+func foldHeader(h, cont []byte, space bool) []byte {
+	if bytes.HasPrefix(h, []byte("Host:")) {
+		return append(h, clean(cont, func(b byte) bool { return isValidToken(b, &hostTokenTable) })...)
+	}
+
+	cont = cleanHeaderValue(append([]byte{}, cont...))
+	if len(cont) == 0 {
+		return h
+	}
+
+	if space && len(h) > 0 && h[len(h)-1] != ':' && h[len(h)-1] != ' ' {
+		h = append(h, ' ')
+	}
+
+	return append(h, cont...)
+}
+
+// isSplitName reports whether fragment, a header line without a colon, is the start of a header
+// name that a line break was inserted into, with the rest of the header on the next line, next.
+// fragment must only contain token characters, optionally followed by whitespace, and joining it
+// with next must give a header with a valid name. Since the rest of a name is usually lowercase,
+// e.g. "ost" of "Host", we assume a next line whose name starts with an uppercase letter is a new
+// header instead, and fragment is the rest of the value of the header before it.
+func isSplitName(fragment, next []byte) bool {
+	isToken := func(b byte) bool { return isValidToken(b, &validTokenTable) }
+	name := bytes.TrimRight(fragment, " \t")
+	if len(name) == 0 || len(clean(name, isToken)) != len(name) {
+		return false
+	}
+
+	rest, _, ok := bytes.Cut(next, []byte(":"))
+	if !ok {
+		return false
+	}
+
+	rest = bytes.TrimRight(rest, " \t")
+	if len(clean(rest, isToken)) != len(rest) {
+		return false
+	}
+
+	return len(rest) == 0 || rest[0] < 'A' || rest[0] > 'Z'
+}
+
 // cleanHeaderValue returns s with all invalid header value characters removed.
 func cleanHeaderValue(s []byte) []byte {
 	// RFC 7230, section 3.2
@@ -558,6 +1066,10 @@ type NormalizationTestResults struct {
 	Name string
 	// Request is the original request before applying the strategy and normalization.
 	Request string
+	// Modified is the request after applying the strategy, but before normalization. It is set
+	// whenever the strategy was applied, even if normalization then failed, to show what the
+	// strategy produced.
+	Modified string
 	// Normalized is the normalized request after applying the strategy and normalization.
 	Normalized string
 	// Msg describes why the test failed if it did. If the test passed but the normalized request
@@ -566,6 +1078,9 @@ type NormalizationTestResults struct {
 	Msg string
 	// Pass reports whether the test passed.
 	Pass bool
+	// Irreversible reports whether the test passed but the original request could not be fully
+	// restored, such as when the strategy replaces the version. Msg describes the differences.
+	Irreversible bool
 }
 
 // TestStrategyNormalization tests if strategy is a valid strategy and whether a request
@@ -575,12 +1090,7 @@ type NormalizationTestResults struct {
 // normalization or if values were inferred. TestStrategyNormalization returns the results of each
 // test and whether the strategy passed all tests.
 func TestStrategyNormalization(strategy string) ([]NormalizationTestResults, bool, error) {
-	strat, err := NewHTTPStrategy(strategy)
-	if err != nil {
-		return nil, false, fmt.Errorf("failed to create strategy from %s: %w", strategy, err)
-	}
-
-	tests := []NormalizationTestResults{
+	return testStrategyNormalization(strategy, []NormalizationTestResults{
 		{
 			Name:    "GET",
 			Request: "GET /some/path HTTP/1.1\r\nHost: example.com\r\n\r\n",
@@ -594,7 +1104,78 @@ func TestStrategyNormalization(strategy string) ([]NormalizationTestResults, boo
 			Name:    "PUT with body",
 			Request: "PUT /some/path HTTP/1.1\r\nHost: example.com\r\n\r\nsome body",
 		},
+	})
+}
+
+// TestStrategyNormalizationWith is the same as TestStrategyNormalization, but tests strategy
+// against requests instead of the default set of requests. Each request must be a valid HTTP/1.0
+// or HTTP/1.1 request. The name of each result is the index of its request in requests.
+func TestStrategyNormalizationWith(
+	strategy string, requests []string,
+) ([]NormalizationTestResults, bool, error) {
+	tests := make([]NormalizationTestResults, len(requests))
+	for i, req := range requests {
+		tests[i] = NormalizationTestResults{
+			Name:    fmt.Sprintf("request %d", i),
+			Request: req,
+		}
+	}
+
+	return testStrategyNormalization(strategy, tests)
+}
+
+// StrategyValidation is the result of testing a strategy with TestStrategyNormalization.
+type StrategyValidation struct {
+	// Strategy is the strategy that was tested.
+	Strategy string
+	// Results are the results of each test. Results is nil if Err is not nil.
+	Results []NormalizationTestResults
+	// Pass reports whether the strategy passed all tests.
+	Pass bool
+	// Irreversible reports whether the strategy passed all tests, but the original request could
+	// not be fully restored in at least one of them.
+	Irreversible bool
+	// Err is the error returned by TestStrategyNormalization, such as when the strategy cannot be
+	// parsed.
+	Err error
+}
+
+// ValidateAllBuiltin runs TestStrategyNormalization on every strategy in Strategies. The results
+// are keyed by country and are in the same order as the strategies of that country.
+func ValidateAllBuiltin() map[string][]StrategyValidation {
+	validations := make(map[string][]StrategyValidation, len(Strategies))
+	for country, strategies := range Strategies {
+		vs := make([]StrategyValidation, len(strategies))
+		for i, strategy := range strategies {
+			results, pass, err := TestStrategyNormalization(strategy)
+			vs[i] = StrategyValidation{
+				Strategy: strategy,
+				Results:  results,
+				Pass:     pass,
+				Err:      err,
+			}
+
+			for _, r := range results {
+				vs[i].Irreversible = vs[i].Irreversible || (pass && r.Irreversible)
+			}
+		}
+
+		validations[country] = vs
+	}
+
+	return validations
+}
+
+// testStrategyNormalization runs the tests for TestStrategyNormalization and
+// TestStrategyNormalizationWith. The Name and Request of each test must be set.
+func testStrategyNormalization(
+	strategy string, tests []NormalizationTestResults,
+) ([]NormalizationTestResults, bool, error) {
+	strat, err := NewHTTPStrategy(strategy)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create strategy from %s: %w", strategy, err)
 	}
+
 	for t := 0; t < len(tests); t++ {
 		test := &tests[t]
 		modReq, err := strat.Apply([]byte(test.Request))
@@ -603,6 +1184,7 @@ func TestStrategyNormalization(strategy string) ([]NormalizationTestResults, boo
 			continue
 		}
 
+		test.Modified = string(modReq)
 		got, err := NormalizeRequest(modReq)
 		test.Normalized = string(got)
 		if err != nil {
@@ -625,8 +1207,12 @@ func TestStrategyNormalization(strategy string) ([]NormalizationTestResults, boo
 		// normalized request might not be the same as the original, so we check if the original
 		// request was fully restored during normalization. If not, then we report which elements
 		// were not restored. This is not a failure, but it is useful for the user to know.
+		// A method or version that could not be found was replaced with a default. The default may
+		// happen to match the original, but it was not restored, so it is reported as well.
 		diffs, _ := getNormalizeTestDiff([]byte(test.Request), got)
+		diffs = append(diffs, getInferredComponents(modReq)...)
 		if len(diffs) > 0 {
+			test.Irreversible = true
 			test.Msg = fmt.Sprintf(
 				"Could not fully restore original request during normalization. %v",
 				strings.Join(diffs, ", "),
@@ -643,6 +1229,27 @@ func TestStrategyNormalization(strategy string) ([]NormalizationTestResults, boo
 	return tests, passed, nil
 }
 
+// getInferredComponents returns which of the method and version of the request line of req cannot
+// be found during normalization and so are replaced with a default.
+func getInferredComponents(req []byte) []string {
+	line, _, _ := bytes.Cut(req, []byte("\r\n"))
+	method, _, version, err := parseRequestLine(line, NormalizeOptions{FoldMethodCase: true})
+	if err != nil {
+		return nil
+	}
+
+	var inferred []string
+	if method == "" {
+		inferred = append(inferred, "method: inferred")
+	}
+
+	if version == "" {
+		inferred = append(inferred, "version: inferred")
+	}
+
+	return inferred
+}
+
 // getNormalizeTestDiff compares the original request with the normalized request and reports any
 // differences. getNormalizeTestDiff only compares the method, path, version, and host.
 func getNormalizeTestDiff(orig, norm []byte) ([]string, error) {
@@ -677,7 +1284,8 @@ func getNormalizeTestDiff(orig, norm []byte) ([]string, error) {
 	getHostForComp := func(req *request) string {
 		h := req.getHeader("host")
 		h = strings.ToLower(h)
-		return strings.TrimSpace(strings.TrimPrefix(h, "host:"))
+		// example.com. and example.com are the same host, so the trailing dot isn't a difference.
+		return trimHostDot(strings.TrimSpace(strings.TrimPrefix(h, "host:")))
 	}
 
 	oHost := getHostForComp(oReq)
@@ -688,3 +1296,15 @@ func getNormalizeTestDiff(orig, norm []byte) ([]string, error) {
 
 	return elemDiffs, nil
 }
+
+// trimHostDot removes the trailing dot of a fully qualified domain name in host, which can include a port, e.g.
+// example.com.:8080 becomes example.com:8080.
+func trimHostDot(host string) string {
+	name, port := host, ""
+	// the port follows the last ':', unless it's part of an IPv6 literal, e.g. [::1].
+	if i := strings.LastIndexByte(host, ':'); i > strings.LastIndexByte(host, ']') {
+		name, port = host[:i], host[i:]
+	}
+
+	return strings.TrimSuffix(name, ".") + port
+}
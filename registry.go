@@ -0,0 +1,58 @@
+package algeneva
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// registry holds the strategies registered with Register keyed by name.
+var registry = struct {
+	sync.RWMutex
+	strategies map[string]*HTTPStrategy
+}{strategies: make(map[string]*HTTPStrategy)}
+
+// Register parses strategy and stores it under name so it can be retrieved with Lookup without being parsed again.
+// Register returns an error if strategy cannot be parsed or if a strategy is already registered under name. It is
+// safe to call Register and Lookup concurrently.
+func Register(name string, strategy string) error {
+	s, err := NewHTTPStrategy(strategy)
+	if err != nil {
+		return fmt.Errorf("failed to register %s: %w", name, err)
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.strategies[name]; ok {
+		return fmt.Errorf("strategy already registered: %s", name)
+	}
+
+	registry.strategies[name] = s
+	return nil
+}
+
+// Lookup returns a copy of the strategy registered under name and whether it was found. Each call returns a new copy,
+// so setting its options or enabling and disabling its rules doesn't affect the registered strategy or other callers.
+func Lookup(name string) (*HTTPStrategy, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	s, ok := registry.strategies[name]
+	if !ok {
+		return nil, false
+	}
+
+	// the triggers and action trees are not modified after they are parsed, so they can be shared.
+	return &HTTPStrategy{
+		Atomic:           s.Atomic,
+		StableRandom:     s.StableRandom,
+		ConnectAuthority: s.ConnectAuthority,
+		rules:            slices.Clone(s.rules),
+	}, true
+}
+
+// unregister removes the strategy registered under name, if any. It is used by tests to undo Register.
+func unregister(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.strategies, name)
+}
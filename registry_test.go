@@ -0,0 +1,67 @@
+package algeneva
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	strategy := "[HTTP:host:*]-insert{%20:start:name:1}-|"
+	require.NoError(t, Register("test-register", strategy))
+	t.Cleanup(func() { unregister("test-register") })
+
+	s, ok := Lookup("test-register")
+	require.True(t, ok)
+	assert.Equal(t, strategy, s.String())
+
+	assert.Error(t, Register("test-register", "[HTTP:path:*]-insert{%20:start:value:1}-|"), "duplicate name")
+	s, _ = Lookup("test-register")
+	assert.Equal(t, strategy, s.String(), "duplicate name must not replace the strategy")
+
+	assert.Error(t, Register("test-register-invalid", "[HTTP:host:*]-insert{%20:start:name:1}"))
+	_, ok = Lookup("test-register-invalid")
+	assert.False(t, ok)
+
+	_, ok = Lookup("test-register-missing")
+	assert.False(t, ok)
+}
+
+func TestRegister_Concurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("test-concurrent-%d", i)
+			t.Cleanup(func() { unregister(name) })
+			assert.NoError(t, Register(name, "[HTTP:host:*]-insert{%20:start:name:1}-|"))
+			_, ok := Lookup(name)
+			assert.True(t, ok)
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestLookup_Copy(t *testing.T) {
+	require.NoError(t, Register("test-lookup-copy", "[HTTP:host:*]-insert{a:start:value}-|"))
+	t.Cleanup(func() { unregister("test-lookup-copy") })
+
+	s, ok := Lookup("test-lookup-copy")
+	require.True(t, ok)
+
+	s.Atomic = true
+	s.SetRuleEnabled(0, false)
+
+	other, ok := Lookup("test-lookup-copy")
+	require.True(t, ok)
+	assert.False(t, other.Atomic)
+
+	got, err := other.Apply([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "GET / HTTP/1.1\r\nHost: aexample.com\r\n\r\n", string(got))
+}
@@ -9,69 +9,269 @@ import (
 // request is an extremely simple HTTP request parser. It only parses the method, path, and version from the start
 // line, and separates the headers and body. It does not parse the headers or body.
 type request struct {
-	method  string
-	path    string
-	version string
-	headers string
-	body    []byte
+	method string
+	// pathPadding and pathTrailer are the extra whitespace before and after the path in the start line, such as
+	// spaces inserted by a strategy, so that the path can be matched and modified without it and bytes still
+	// reproduces the start line exactly.
+	pathPadding string
+	path        string
+	pathTrailer string
+	version     string
+	headers     string
+	body        []byte
 }
 
 // newRequest parses a byte slice, req, into a request. newRequest returns an error if req is not a valid HTTP request.
+// For convenience, a request without a body can omit the empty line that ends the headers, as long as the last line
+// ends with CRLF.
 func newRequest(req []byte) (*request, error) {
 	// Find the index of the end of the headers.
-	idx := bytes.Index(req, []byte("\r\n\r\n"))
-	if idx == -1 {
+	var head, body []byte
+	if idx := bytes.Index(req, []byte("\r\n\r\n")); idx != -1 {
+		head, body = req[:idx], req[idx+4:]
+	} else if bytes.HasSuffix(req, []byte("\r\n")) {
+		head, body = req[:len(req)-2], []byte{}
+	} else {
 		return nil, fmt.Errorf("invalid request: %s", req)
 	}
 
 	// Split the request into the start line, rest, and body.
-	startLine, headers, _ := bytes.Cut(req[:idx], []byte("\r\n"))
-	// Split the start line into the method, path, and version.
-	mpv := strings.Split(string(startLine), " ")
-	if len(mpv) != 3 {
+	startLine, headers, _ := bytes.Cut(head, []byte("\r\n"))
+	// Split the start line into the method, path, and version. The method is the first space-separated field and
+	// the version starts at the last " HTTP/1.0" or " HTTP/1.1", or is the last space-separated field if there is
+	// neither, so that a version with bytes inserted after it by a strategy, which can include spaces, is still
+	// found. Everything in between is the path, except for any extra whitespace around it, such as spaces inserted by
+	// a strategy, which is kept separately.
+	line := string(startLine)
+	i := strings.IndexByte(line, ' ')
+	j := max(strings.LastIndex(line, " HTTP/1.0"), strings.LastIndex(line, " HTTP/1.1"))
+	if j <= i {
+		j = strings.LastIndexByte(line, ' ')
+	}
+
+	if i <= 0 || i == j {
 		return nil, fmt.Errorf("invalid request: %s", req)
 	}
 
+	method, path, version := line[:i], line[i+1:j], line[j+1:]
+	trimmed := strings.TrimLeft(path, " \t")
+	padding := path[:len(path)-len(trimmed)]
+	path = strings.TrimRight(trimmed, " \t")
+	trailer := trimmed[len(path):]
+
 	// The scope of application layer Geveva was specifically for HTTP version 1 (HTTP/1.0 and HTTP/1.1), so we only
-	// support HTTP/1.0 and HTTP/1.1. (page 5)
-	if mpv[2] != "HTTP/1.0" && mpv[2] != "HTTP/1.1" {
-		return nil, fmt.Errorf("unsupported HTTP version: %s", mpv[2])
+	// support HTTP/1.0 and HTTP/1.1. (page 5) Anything after the version, such as bytes inserted by a strategy, is
+	// kept as part of it.
+	if !strings.HasPrefix(version, "HTTP/1.0") && !strings.HasPrefix(version, "HTTP/1.1") {
+		return nil, fmt.Errorf("unsupported HTTP version: %s", version)
 	}
 
 	return &request{
-		method:  mpv[0],
-		path:    mpv[1],
-		version: mpv[2],
-		headers: string(headers),
-		body:    req[idx+4:],
+		method:      method,
+		pathPadding: padding,
+		path:        path,
+		pathTrailer: trailer,
+		version:     version,
+		headers:     string(headers),
+		body:        body,
 	}, nil
 }
 
 // bytes merges the head and body of the request back into a []byte and returns it.
 func (r *request) bytes() []byte {
-	head := fmt.Sprintf("%s %s %s\r\n%s\r\n\r\n", r.method, r.path, r.version, r.headers)
+	return r.appendBytes(nil)
+}
 
-	size := len(head) + len(r.body)
-	buf := make([]byte, size)
+// appendBytes appends the head and body of the request to dst and returns the extended slice. Callers can reuse
+// dst to avoid allocating a new slice for each request.
+func (r *request) appendBytes(dst []byte) []byte {
+	size := len(r.method) + len(r.pathPadding) + len(r.path) + len(r.pathTrailer) + len(r.version) + len(r.headers) +
+		len(r.body) + 8
+	if cap(dst)-len(dst) < size {
+		dst = append(make([]byte, 0, len(dst)+size), dst...)
+	}
 
-	copy(buf, head)
-	copy(buf[len(head):], r.body)
+	dst = append(dst, r.method...)
+	dst = append(dst, ' ')
+	dst = append(dst, r.pathPadding...)
+	dst = append(dst, r.path...)
+	dst = append(dst, r.pathTrailer...)
+	dst = append(dst, ' ')
+	dst = append(dst, r.version...)
+	dst = append(dst, "\r\n"...)
+	if r.headers != "" {
+		dst = append(dst, r.headers...)
+		dst = append(dst, "\r\n"...)
+	}
 
-	return buf
+	dst = append(dst, "\r\n"...)
+	return append(dst, r.body...)
 }
 
-// getHeader returns the full header, including the name, if it exists. getHeader is case insensitive.
+// getHeader returns the full header, including the name, if it exists. getHeader is case insensitive and allows
+// whitespace between the name and the colon.
 func (r *request) getHeader(name string) string {
-	headers := strings.ToLower(r.headers)
-	idx := strings.Index(headers, name+":")
-	if idx == -1 {
-		return ""
+	// scan the headers line by line instead of lowercasing all of them so we don't allocate a copy of the headers
+	// on every call.
+	headers := r.headers
+	for len(headers) > 0 {
+		var line string
+		line, headers, _ = strings.Cut(headers, "\r\n")
+		if len(line) <= len(name) || !strings.EqualFold(line[:len(name)], name) {
+			continue
+		}
+
+		if rest := strings.TrimLeft(line[len(name):], " \t"); rest != "" && rest[0] == ':' {
+			return line
+		}
+	}
+
+	return ""
+}
+
+// getJoinedHeader returns the values of every occurrence of the header name joined with ", ", as a recipient would
+// combine them (RFC 7230 section 3.2.2), and reports whether the header exists. The name is matched like getHeader.
+func (r *request) getJoinedHeader(name string) (string, bool) {
+	var values []string
+	headers := r.headers
+	for len(headers) > 0 {
+		var line string
+		line, headers, _ = strings.Cut(headers, "\r\n")
+		if len(line) <= len(name) || !strings.EqualFold(line[:len(name)], name) {
+			continue
+		}
+
+		if rest := strings.TrimLeft(line[len(name):], " \t"); rest != "" && rest[0] == ':' {
+			values = append(values, strings.Trim(rest[1:], " \t"))
+		}
+	}
+
+	return strings.Join(values, ", "), values != nil
+}
+
+// replaceHeader replaces the first header line that is exactly old with new and reports whether it was found. Only
+// whole lines are compared so a header that contains old, such as X-Host when replacing Host, is not modified.
+func (r *request) replaceHeader(old, new string) bool {
+	i, end, ok := r.findHeaderLine(old)
+	if ok {
+		r.headers = r.headers[:i] + new + r.headers[end:]
+	}
+
+	return ok
+}
+
+// removeHeader removes the first header line that is exactly h and reports whether it was found.
+func (r *request) removeHeader(h string) bool {
+	i, end, ok := r.findHeaderLine(h)
+	if !ok {
+		return false
 	}
 
-	nl := strings.Index(r.headers[idx:], "\r\n")
-	if nl == -1 {
-		nl = len(r.headers[idx:])
+	// remove the line along with one of the CRLFs around it.
+	switch {
+	case end < len(r.headers):
+		r.headers = r.headers[:i] + r.headers[end+2:]
+	case i > 0:
+		r.headers = r.headers[:i-2]
+	default:
+		r.headers = ""
+	}
+
+	return true
+}
+
+// findHeaderLine returns the start and end indices of the first header line that is exactly h, excluding the CRLF,
+// and reports whether it was found.
+func (r *request) findHeaderLine(h string) (start, end int, ok bool) {
+	for start < len(r.headers) {
+		end = strings.Index(r.headers[start:], "\r\n")
+		if end == -1 {
+			end = len(r.headers)
+		} else {
+			end += start
+		}
+
+		if r.headers[start:end] == h {
+			return start, end, true
+		}
+
+		start = end + 2
+	}
+
+	return 0, 0, false
+}
+
+// setHeader sets the value of the first header with the given name, which is matched like getHeader, to value,
+// keeping the name as it is in the request. If there is no such header, it is added with addHeader.
+func (r *request) setHeader(name, value string) {
+	old := r.getHeader(name)
+	if old == "" {
+		r.addHeader(name, value)
+		return
+	}
+
+	oldName, _, _ := strings.Cut(old, ":")
+	r.replaceHeader(old, oldName+": "+value)
+}
+
+// addHeader appends a header with the given name and value to the end of the headers, even if a header with the same
+// name already exists.
+func (r *request) addHeader(name, value string) {
+	r.addHeaderLine(name + ": " + value)
+}
+
+// addHeaderLine appends the header line h to the end of the headers.
+func (r *request) addHeaderLine(h string) {
+	if r.headers == "" {
+		r.headers = h
+		return
+	}
+
+	r.headers += "\r\n" + h
+}
+
+// splitAbsoluteForm splits path into its scheme, authority, and the rest of the path, including the leading '/', if
+// path is in absolute-form, e.g. http://example.com/some/path. ok is false if path is not in absolute-form.
+func splitAbsoluteForm(path string) (scheme, authority, rest string, ok bool) {
+	scheme, rest, ok = strings.Cut(path, "://")
+	// the origin-form can contain "://" in the query, so the scheme can't contain any of the path delimiters.
+	if !ok || scheme == "" || strings.ContainsAny(scheme, "/?#") {
+		return "", "", "", false
+	}
+
+	i := strings.IndexAny(rest, "/?#")
+	if i == -1 {
+		i = len(rest)
+	}
+
+	return scheme, rest[:i], rest[i:], true
+}
+
+// findQueryParam returns the start and end indices in path of the value of the first query parameter named name and
+// reports whether it was found. Names are compared case-insensitively and without decoding them. A parameter without
+// a '=' has no value, so it is not found.
+func findQueryParam(path, name string) (start, end int, ok bool) {
+	i := strings.IndexByte(path, '?')
+	if i == -1 {
+		return 0, 0, false
+	}
+
+	query := path[i+1:]
+	if j := strings.IndexByte(query, '#'); j != -1 {
+		query = query[:j]
+	}
+
+	start = i + 1
+	for query != "" {
+		param, rest, _ := strings.Cut(query, "&")
+		if key, value, ok := strings.Cut(param, "="); ok && strings.EqualFold(key, name) {
+			start += len(key) + 1
+			return start, start + len(value), true
+		}
+
+		start += len(param) + 1
+		query = rest
 	}
 
-	return r.headers[idx : idx+nl]
+	return 0, 0, false
 }
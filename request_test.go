@@ -0,0 +1,353 @@
+package algeneva
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     string
+		want    *request
+		wantErr bool
+	}{
+		{
+			name: "single spaces",
+			req:  "GET /some/path HTTP/1.1\r\nHost: example.com\r\n\r\nbody",
+			want: &request{
+				method:  "GET",
+				path:    "/some/path",
+				version: "HTTP/1.1",
+				headers: "Host: example.com",
+				body:    []byte("body"),
+			},
+		}, {
+			name: "double spaces",
+			req:  "GET  /some/path  HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want: &request{
+				method:      "GET",
+				pathPadding: " ",
+				path:        "/some/path",
+				pathTrailer: " ",
+				version:     "HTTP/1.1",
+				headers:     "Host: example.com",
+				body:        []byte{},
+			},
+		}, {
+			name: "space in path",
+			req:  "GET /some /path HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want: &request{
+				method:  "GET",
+				path:    "/some /path",
+				version: "HTTP/1.1",
+				headers: "Host: example.com",
+				body:    []byte{},
+			},
+		}, {
+			name: "no headers",
+			req:  "GET / HTTP/1.1\r\n\r\n",
+			want: &request{method: "GET", path: "/", version: "HTTP/1.1", headers: "", body: []byte{}},
+		}, {
+			name:    "missing component",
+			req:     "GET HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			wantErr: true,
+		}, {
+			name:    "leading space",
+			req:     " GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			wantErr: true,
+		}, {
+			name: "trailing space",
+			req:  "GET / HTTP/1.1 \r\nHost: example.com\r\n\r\n",
+			want: &request{
+				method:  "GET",
+				path:    "/",
+				version: "HTTP/1.1 ",
+				headers: "Host: example.com",
+				body:    []byte{},
+			},
+		}, {
+			name: "version with inserted bytes",
+			req:  "GET / HTTP/1.1%\t \r\nHost: example.com\r\n\r\n",
+			want: &request{
+				method:  "GET",
+				path:    "/",
+				version: "HTTP/1.1%\t ",
+				headers: "Host: example.com",
+				body:    []byte{},
+			},
+		}, {
+			name: "version with inserted bytes and spaces in path",
+			req:  "GET /a b HTTP/1.0 x y\r\n\r\n",
+			want: &request{method: "GET", path: "/a b", version: "HTTP/1.0 x y", headers: "", body: []byte{}},
+		}, {
+			name:    "unsupported version",
+			req:     "GET / HTTP/2\r\nHost: example.com\r\n\r\n",
+			wantErr: true,
+		}, {
+			name:    "junk before version",
+			req:     "GET / xHTTP/1.1\r\nHost: example.com\r\n\r\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newRequest([]byte(tt.req))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.req, string(got.bytes()))
+		})
+	}
+}
+
+func TestNewRequest_MissingEmptyLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "headers",
+			req:  "GET / HTTP/1.1\r\nHost: example.com\r\n",
+			want: "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name: "no headers",
+			req:  "GET / HTTP/1.1\r\n",
+			want: "GET / HTTP/1.1\r\n\r\n",
+		}, {
+			name:    "missing CRLF",
+			req:     "GET / HTTP/1.1\r\nHost: example.com",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newRequest([]byte(tt.req))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got.bytes()))
+		})
+	}
+}
+
+func TestRequest_GetHeader(t *testing.T) {
+	req := &request{
+		headers: "hOsT: example.com\r\nX-Forwarded-Host: other.com\r\nACCEPT: */*\r\nUser-Agent : curl\r\ncontent-length: 10",
+	}
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"mixed case", "host", "hOsT: example.com"},
+		{"whitespace before colon", "user-agent", "User-Agent : curl"},
+		{"upper case", "accept", "ACCEPT: */*"},
+		{"last header", "content-length", "content-length: 10"},
+		{"suffix of another header", "forwarded-host", ""},
+		{"prefix of another header", "content", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, req.getHeader(tt.header))
+		})
+	}
+}
+
+func TestRequest_GetJoinedHeader(t *testing.T) {
+	req := &request{
+		headers: "Cookie: a=1\r\nHost: example.com\r\ncookie :  b=2 \r\nX-Cookie: c=3",
+	}
+
+	got, ok := req.getJoinedHeader("cookie")
+	assert.True(t, ok)
+	assert.Equal(t, "a=1, b=2", got)
+
+	got, ok = req.getJoinedHeader("host")
+	assert.True(t, ok)
+	assert.Equal(t, "example.com", got)
+
+	_, ok = req.getJoinedHeader("accept")
+	assert.False(t, ok)
+}
+
+func TestRequest_ReplaceHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers string
+		old     string
+		new     string
+		want    string
+	}{
+		{
+			name:    "only header",
+			headers: "Host: example.com",
+			old:     "Host: example.com",
+			new:     "Host: a.com",
+			want:    "Host: a.com",
+		}, {
+			name:    "skip header containing old",
+			headers: "X-Host: example.com\r\nHost: example.com\r\nAccept: */*",
+			old:     "Host: example.com",
+			new:     "Host: a.com\r\nHost: b.com",
+			want:    "X-Host: example.com\r\nHost: a.com\r\nHost: b.com\r\nAccept: */*",
+		}, {
+			name:    "first of duplicates",
+			headers: "Host: example.com\r\nHost: example.com",
+			old:     "Host: example.com",
+			new:     "Host: a.com",
+			want:    "Host: a.com\r\nHost: example.com",
+		}, {
+			name:    "not found",
+			headers: "Host: example.com",
+			old:     "Accept: */*",
+			new:     "Accept: text/html",
+			want:    "Host: example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &request{headers: tt.headers}
+			req.replaceHeader(tt.old, tt.new)
+			assert.Equal(t, tt.want, req.headers)
+		})
+	}
+}
+
+func TestRequest_AppendBytes(t *testing.T) {
+	req, err := newRequest([]byte("GET /some/path HTTP/1.1\r\nHost: example.com\r\nAccept: */*\r\n\r\nsome body"))
+	require.NoError(t, err)
+
+	assert.Equal(t, req.bytes(), req.appendBytes(nil))
+
+	dst := []byte("prefix")
+	assert.Equal(t, append([]byte("prefix"), req.bytes()...), req.appendBytes(dst))
+}
+
+func TestRequest_RemoveHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers string
+		h       string
+		want    string
+		found   bool
+	}{
+		{"only header", "Host: example.com", "Host: example.com", "", true},
+		{"first header", "Host: example.com\r\nAccept: */*", "Host: example.com", "Accept: */*", true},
+		{"middle header", "A: b\r\nHost: example.com\r\nC: d", "Host: example.com", "A: b\r\nC: d", true},
+		{"last header", "A: b\r\nHost: example.com", "Host: example.com", "A: b", true},
+		{"skip header containing h", "X-Host: example.com", "Host: example.com", "X-Host: example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &request{headers: tt.headers}
+			assert.Equal(t, tt.found, req.removeHeader(tt.h))
+			assert.Equal(t, tt.want, req.headers)
+		})
+	}
+}
+
+func TestRequest_SetHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers string
+		value   string
+		want    string
+	}{
+		{"existing header", "hOsT: example.com\r\nAccept: */*", "a.com", "hOsT: a.com\r\nAccept: */*"},
+		{"first of duplicates", "Host: example.com\r\nHost: b.com", "a.com", "Host: a.com\r\nHost: b.com"},
+		{"skip header containing name", "X-Host: example.com", "a.com", "X-Host: example.com\r\nHost: a.com"},
+		{"new header", "Accept: */*", "a.com", "Accept: */*\r\nHost: a.com"},
+		{"no headers", "", "a.com", "Host: a.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &request{headers: tt.headers}
+			req.setHeader("Host", tt.value)
+			assert.Equal(t, tt.want, req.headers)
+		})
+	}
+}
+
+func TestRequest_AddHeader(t *testing.T) {
+	req := &request{}
+	req.addHeader("Host", "example.com")
+	req.addHeader("Host", "a.com")
+	assert.Equal(t, "Host: example.com\r\nHost: a.com", req.headers)
+
+	req, err := newRequest([]byte("GET / HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+	req.addHeader("Host", "example.com")
+	assert.Equal(t, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n", string(req.bytes()))
+}
+
+func TestFindQueryParam(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		param string
+		want  string
+		found bool
+	}{
+		{"first", "/a?utm_source=x&b=y", "utm_source", "x", true},
+		{"last", "/a?b=y&utm_source=x", "utm_source", "x", true},
+		{"first of duplicates", "/a?k=1&k=2", "k", "1", true},
+		{"case insensitive", "/a?UTM_Source=x", "utm_source", "x", true},
+		{"empty value", "/a?k=&b=y", "k", "", true},
+		{"fragment", "/a?k=v#k=w", "k", "v", true},
+		{"in fragment only", "/a?b=y#k=w", "k", "", false},
+		{"without value", "/a?k&b=y", "k", "", false},
+		{"prefix of another", "/a?key=v", "k", "", false},
+		{"no query", "/a", "k", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := findQueryParam(tt.path, tt.param)
+			assert.Equal(t, tt.found, ok)
+			if ok {
+				assert.Equal(t, tt.want, tt.path[start:end])
+			}
+		})
+	}
+}
+
+func BenchmarkRequest_AppendBytes(b *testing.B) {
+	req, err := newRequest([]byte("GET /some/path HTTP/1.1\r\nHost: example.com\r\nAccept: */*\r\n\r\nsome body"))
+	require.NoError(b, err)
+
+	var buf []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = req.appendBytes(buf[:0])
+	}
+}
+
+func BenchmarkRequest_GetHeader(b *testing.B) {
+	var headers []string
+	for i := 0; i < 30; i++ {
+		headers = append(headers, fmt.Sprintf("X-Header-%d: value-%d", i, i))
+	}
+
+	headers = append(headers, "Host: example.com")
+	req := &request{headers: strings.Join(headers, "\r\n")}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req.getHeader("host")
+	}
+}
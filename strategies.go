@@ -1,172 +1,248 @@
 package algeneva
 
-// Strategies is a map of geneva strategies keyed to the country they were found to work in.
+// StrategiesDetailed is a map of geneva strategies, with metadata about each, keyed to the country they were found to
+// work in.
 //
-// Note: China has two sets of strategies, one for hostname censoring and one for keyword censoring. Hostname censor
-// strategies are at indices 0-29, and keyword censor strategies start at indices 30.
-var Strategies = map[string][]string{
-	"China": {
+// China has two sets of strategies, one for hostname censoring and one for keyword censoring, which is recorded in the
+// CensorKind of each entry. The kind of censorship is unknown for the other countries.
+var StrategiesDetailed = map[string][]StrategyEntry{
+	"China": append(
 		// hostname censor strategies //
-		"[HTTP:version:*]-insert{%09:middle:value:14}-|",
-		"[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:host:*]-duplicate(replace{/:name:64}(replace{/?ultrasurf:value},),)-|",
-		"[HTTP:host:*]-duplicate(replace{a:name:64},)-|",
-		"[HTTP:method:*]-insert{%20:end:value:1}-|[HTTP:host:*]-duplicate(replace{%2F:name:64},)-|",
-		"[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:host:*]-duplicate(replace{%C2%B0:name:32},)-|",
-		"[HTTP:host:*]-insert{%20%0A:start:name:1}-|",
-		"[HTTP:host:*]-insert{%20:start:name:1}-|",
-		"[HTTP:method:*]-duplicate(,replace{a:name:1407})-|",
-		"[HTTP:method:*]-insert{%0A:start:value:4336}-|",
-		"[HTTP:method:*]-insert{%20:end:value:1413}-|",
-		"[HTTP:method:*]-insert{%20:end:value:1720}-|",
-		"[HTTP:path:*]-insert{%0D:end:value:1434}-|",
-		"[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:path:*]-replace{3:value:511}(insert{&:start:value},)-|",
-		"[HTTP:path:*]-insert{%3F:start:value:1413}-|",
-		"[HTTP:version:*]-insert{%25:middle:value:1434}-|",
-		"[HTTP:version:*]-insert{%C3%8B:middle:value:717}-|",
-		"[HTTP:method:*]-replace{%3A:value:1}-|",
-		"[HTTP:method:*]-replace{HTTP/1.1:value:1}-|",
-		"[HTTP:path:*]-insert{%3F:start:value:1}-|",
-		"[HTTP:method:*]-insert{%0D:end:value:2}-|",
-		"[HTTP:path:*]-insert{%09:start:value:1}-|",
-		"[HTTP:path:*]-insert{%0C:start:value:1}-|",
-		"[HTTP:path:*]-insert{%0D:start:value:1}-|",
-		"[HTTP:path:*]-insert{%20:start:value:1}-|",
-		"[HTTP:host:*]-duplicate(replace{%C3%97:name:596},insert{%20:end:name:786})-|",
-		"[HTTP:host:*]-replace{%5E:name:926}(duplicate(duplicate(,replace{host:name:1}(insert{%20:start:value:3238},)),),)-|",
-		"[HTTP:host:*]-replace{%C3%97:name:1358}(duplicate(duplicate(,replace{host:name:1}(insert{%20:end:value},)),),)-|",
-		"[HTTP:host:*]-replace{%C3%97:name:1371}(duplicate(duplicate(,replace{host:name:1}),),)-|",
-		"[HTTP:host:*]-replace{PUT:name:423}(duplicate(duplicate(,replace{host:name}),),)-|",
-		"[HTTP:version:*]-replace{OPTIONS:value:1}-|",
-
+		strategyEntries(CensorHostname, []StrategyEntry{
+			{Strategy: "[HTTP:version:*]-insert{%09:middle:value:14}-|"},
+			{Strategy: "[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:host:*]-duplicate(replace{/:name:64}(replace{/?ultrasurf:value},),)-|"},
+			{Strategy: "[HTTP:host:*]-duplicate(replace{a:name:64},)-|"},
+			{Strategy: "[HTTP:method:*]-insert{%20:end:value:1}-|[HTTP:host:*]-duplicate(replace{%2F:name:64},)-|"},
+			{Strategy: "[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:host:*]-duplicate(replace{%C2%B0:name:32},)-|"},
+			{Strategy: "[HTTP:host:*]-insert{%20%0A:start:name:1}-|"},
+			{Strategy: "[HTTP:host:*]-insert{%20:start:name:1}-|"},
+			{Strategy: "[HTTP:method:*]-duplicate(,replace{a:name:1407})-|"},
+			{Strategy: "[HTTP:method:*]-insert{%0A:start:value:4336}-|"},
+			{Strategy: "[HTTP:method:*]-insert{%20:end:value:1413}-|"},
+			{Strategy: "[HTTP:method:*]-insert{%20:end:value:1720}-|"},
+			{Strategy: "[HTTP:path:*]-insert{%0D:end:value:1434}-|"},
+			{Strategy: "[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:path:*]-replace{3:value:511}(insert{&:start:value},)-|"},
+			{Strategy: "[HTTP:path:*]-insert{%3F:start:value:1413}-|"},
+			{Strategy: "[HTTP:version:*]-insert{%25:middle:value:1434}-|"},
+			{Strategy: "[HTTP:version:*]-insert{%C3%8B:middle:value:717}-|"},
+			{Strategy: "[HTTP:method:*]-replace{%3A:value:1}-|", Notes: noteMethodReplaced},
+			{Strategy: "[HTTP:method:*]-replace{HTTP/1.1:value:1}-|", Notes: noteMethodReplaced},
+			{Strategy: "[HTTP:path:*]-insert{%3F:start:value:1}-|"},
+			{Strategy: "[HTTP:method:*]-insert{%0D:end:value:2}-|"},
+			{Strategy: "[HTTP:path:*]-insert{%09:start:value:1}-|"},
+			{Strategy: "[HTTP:path:*]-insert{%0C:start:value:1}-|"},
+			{Strategy: "[HTTP:path:*]-insert{%0D:start:value:1}-|"},
+			{Strategy: "[HTTP:path:*]-insert{%20:start:value:1}-|"},
+			{Strategy: "[HTTP:host:*]-duplicate(replace{%C3%97:name:596},insert{%20:end:name:786})-|"},
+			{Strategy: "[HTTP:host:*]-replace{%5E:name:926}(duplicate(duplicate(,replace{host:name:1}(insert{%20:start:value:3238},)),),)-|"},
+			{Strategy: "[HTTP:host:*]-replace{%C3%97:name:1358}(duplicate(duplicate(,replace{host:name:1}(insert{%20:end:value},)),),)-|"},
+			{Strategy: "[HTTP:host:*]-replace{%C3%97:name:1371}(duplicate(duplicate(,replace{host:name:1}),),)-|"},
+			{Strategy: "[HTTP:host:*]-replace{PUT:name:423}(duplicate(duplicate(,replace{host:name}),),)-|"},
+			{Strategy: "[HTTP:version:*]-replace{OPTIONS:value:1}-|", Notes: noteVersionReplaced},
+		}),
 		// keyword censor strategies //
-		"[HTTP:version:*]-insert{%09:middle:value:14}-|",
-		"[HTTP:path:*]-insert{%09:end:value:1434}-|[HTTP:path:*]-insert{1:start:value:507}-|",
-		"[HTTP:path:*]-insert{%20:end:value:1}-|[HTTP:path:*]-insert{g:end:value:1013}-|",
-		"[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:host:*]-duplicate(replace{/:name:64}(replace{/?ultrasurf:value},),)-|",
-		"[HTTP:host:*]-duplicate(replace{a:name:64},)-|",
-		"[HTTP:method:*]-insert{%20:end:value:1}-|[HTTP:host:*]-duplicate(replace{%2F:name:64},)-|",
-		"[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:host:*]-duplicate(replace{%C2%B0:name:32},)-|",
-		"[HTTP:method:*]-insert{%0A:start:value:4336}-|",
-		"[HTTP:path:*]-insert{%0D:end:value:1434}-|",
-		"[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:path:*]-replace{3:value:511}(insert{&:start:value},)-|",
-		"[HTTP:version:*]-insert{%25:middle:value:1434}-|",
-		"[HTTP:version:*]-insert{%C3%8B:middle:value:717}-|",
-		"[HTTP:method:*]-replace{%3A:value:1}-|",
-		"[HTTP:method:*]-replace{HTTP/1.1:value:1}-|",
-		"[HTTP:path:*]-duplicate(insert{3:middle:value:1004},replace{&ultrasurf:value})-|",
-		"[HTTP:method:*]-insert{%0D:end:value:2}-|",
-		"[HTTP:path:*]-insert{%0D:start:value:1}-|",
-		"[HTTP:host:*]-duplicate(replace{%C3%97:name:596},insert{%20:end:name:786})-|",
-		"[HTTP:host:*]-replace{%5E:name:926}(duplicate(duplicate(,replace{host:name:1}(insert{%20:start:value:3238},)),),)-|",
-		"[HTTP:host:*]-replace{%C3%97:name:1358}(duplicate(duplicate(,replace{host:name:1}(insert{%20:end:value},)),),)-|",
-		"[HTTP:host:*]-replace{%C3%97:name:1371}(duplicate(duplicate(,replace{host:name:1}),),)-|",
-		"[HTTP:host:*]-insert{%20:end:value:4081}(duplicate(duplicate(,replace{a:name:1}),insert{%09:start:name:3238}),)-|",
-		"[HTTP:host:*]-insert{%20:end:value:4081}(duplicate(duplicate(insert{%09:start:name:3238},),replace{a:name:1}),)-|",
-		"[HTTP:host:*]-replace{PUT:name:423}(duplicate(duplicate(,replace{host:name}),),)-|",
-		"[HTTP:version:*]-replace{OPTIONS:value:1}-|",
-	},
-	"India": {
-		"[HTTP:host:*]-changecase{lower}-|",
-		"[HTTP:host:*]-changecase{upper}-|",
-		"[HTTP:version:*]-insert{%09:middle:value:14}-|",
-		"[HTTP:path:*]-insert{%09:end:value:1434}-|[HTTP:path:*]-insert{1:start:value:507}-|",
-		"[HTTP:path:*]-insert{%20:end:value:1}-|[HTTP:path:*]-insert{g:end:value:1013}-|",
-		"[HTTP:method:*]-insert{%09:end:value}-|[HTTP:host:*]-duplicate(replace{a:name:64},)-|",
-		"[HTTP:method:*]-insert{%0A:start:value:1}-|[HTTP:host:*]-duplicate(replace{%2F:name:64},)-|",
-		"[HTTP:host:*]-duplicate(insert{%0A:end:value:1},)-|",
-		"[HTTP:host:*]-duplicate(insert{%0A:random:name:1},)-|",
-		"[HTTP:host:*]-duplicate(insert{%20%0A:end:name:1},)-|",
-		"[HTTP:host:*]-insert{%09:end:name}-|",
-		"[HTTP:host:*]-insert{%0A%0A:start:value:1}-|",
-		"[HTTP:host:*]-insert{%0A%20:start:value:1}-|",
-		"[HTTP:host:*]-insert{%0A:end:value:1}-|",
-		"[HTTP:host:*]-insert{%20%0A:start:name:1}-|",
-		"[HTTP:host:*]-insert{%20:end:name:1}-|",
-		"[HTTP:host:*]-insert{%20:start:name:1}-|",
-		"[HTTP:path:*]-replace{/:value:1434}-|",
-		"[HTTP:host:*]-insert{%20:start:value:1413}-|",
-		"[HTTP:host:*]-insert{%20:start:value:1434}-|",
-		"[HTTP:method:*]-duplicate(,replace{a:name:1407})-|",
-		"[HTTP:method:*]-insert{%09:end:value:2568}-|",
-		"[HTTP:method:*]-insert{%0A:start:value:4336}-|",
-		"[HTTP:method:*]-insert{%20:end:value:1413}-|",
-		"[HTTP:method:*]-insert{%20:end:value:1720}-|",
-		"[HTTP:path:*]-duplicate(replace{a:name:1}(insert{a:start:value:1408},),)-|",
-		"[HTTP:path:*]-insert{%0D:end:value:1434}-|",
-		"[HTTP:path:*]-insert{%20:end:value:1413}-|",
-		"[HTTP:path:*]-insert{%23:end:value:1413}-|",
-		"[HTTP:path:*]-insert{%23:end:value:1}(insert{%C3:end:value:470},)-|",
-		"[HTTP:path:*]-insert{%3F:end:value:1413}-|",
-		"[HTTP:path:*]-insert{%3F:start:value:1413}-|",
-		"[HTTP:path:*]-replace{/:value:1414}-|",
-		"[HTTP:version:*]-insert{%20:end:value:1434}-|",
-		"[HTTP:version:*]-insert{%20:start:value:1434}-|",
-		"[HTTP:version:*]-insert{%25:middle:value:1434}-|",
-		"[HTTP:version:*]-insert{%C2%81:end:value:773}-|",
-		"[HTTP:version:*]-insert{%C3%8B:middle:value:717}-|",
-		"[HTTP:method:*]-replace{%3A:value:1}-|",
-		"[HTTP:method:*]-duplicate(,)-|",
-		"[HTTP:method:*]-replace{HTTP/1.1:value:1}-|",
-		"[HTTP:path:*]-duplicate(insert{3:middle:value:1004},replace{&ultrasurf:value})-|",
-		"[HTTP:path:*]-insert{%3F:start:value:1}-|",
-		"[HTTP:method:*]-insert{%09:end:value:1}-|",
-		"[HTTP:method:*]-insert{%09:start:value:1}-|",
-		"[HTTP:method:*]-insert{%0A:start:value:1}-|",
-		"[HTTP:method:*]-insert{%0B:end:value:1}-|",
-		"[HTTP:method:*]-insert{%0D:end:value:2}-|",
-		"[HTTP:path:*]-insert{%09:end:value:1}-|",
-		"[HTTP:path:*]-insert{%09:start:value:1}-|",
-		"[HTTP:path:*]-insert{%0C:start:value:1}-|",
-		"[HTTP:path:*]-insert{%0D:start:value:1}-|",
-		"[HTTP:path:*]-insert{%20:end:value:1}-|",
-		"[HTTP:version:*]-insert{%0A%09%0A%09:end:value:1}-|",
-		"[HTTP:version:*]-insert{%0A%20%0A%20:end:value:1}-|",
-		"[HTTP:version:*]-insert{%20%0A%09:end:value:1}-|",
-		"[HTTP:version:*]-insert{%20:end:value:1}-|",
-		"[HTTP:host:*]-duplicate(replace{%C3%97:name:596},insert{%20:end:name:786})-|",
-		"[HTTP:host:*]-replace{%C3%97:name:1358}(duplicate(duplicate(,replace{host:name:1}(insert{%20:end:value},)),),)-|",
-		"[HTTP:host:*]-replace{%C3%97:name:1371}(duplicate(duplicate(,replace{host:name:1}),),)-|",
-		"[HTTP:host:*]-replace{PUT:name:423}(duplicate(duplicate(,replace{host:name}),),)-|",
-		"[HTTP:version:*]-replace{OPTIONS:value:1}-|",
-		"[HTTP:version:*]-duplicate-|",
-	},
-	"Kazakhstan": {
-		"[HTTP:method:*]-insert{%09:end:value}-|[HTTP:host:*]-duplicate(replace{a:name:64},)-|",
-		"[HTTP:method:*]-insert{%0A:start:value:1}-|[HTTP:host:*]-duplicate(replace{%2F:name:64},)-|",
-		"[HTTP:host:*]-insert{%09:end:name}-|",
-		"[HTTP:host:*]-insert{%09:end:value:1}-|",
-		"[HTTP:host:*]-insert{%09:start:value:1}-|",
-		"[HTTP:host:*]-insert{%0A%0A:start:value:1}-|",
-		"[HTTP:host:*]-insert{%0A%20:start:value:1}-|",
-		"[HTTP:host:*]-insert{%20%0A:start:name:1}-|",
-		"[HTTP:host:*]-insert{%20:end:name:1}-|",
-		"[HTTP:host:*]-insert{%20:end:value:1}-|",
-		"[HTTP:host:*]-insert{%20:start:name:1}-|",
-		"[HTTP:host:*]-insert{%20:start:value:1434}-|",
-		"[HTTP:method:*]-insert{%09:end:value:2568}-|",
-		"[HTTP:method:*]-insert{%0A:start:value:4336}-|",
-		"[HTTP:method:*]-insert{%20:end:value:1720}-|",
-		"[HTTP:version:*]-insert{%20:end:value:1434}-|",
-		"[HTTP:version:*]-insert{%20:start:value:1434}-|",
-		"[HTTP:version:*]-insert{%25:middle:value:1434}-|",
-		"[HTTP:version:*]-insert{%C2%81:end:value:773}-|",
-		"[HTTP:version:*]-insert{%C3%8B:middle:value:717}-|",
-		"[HTTP:method:*]-replace{%3A:value:1}-|",
-		"[HTTP:method:*]-duplicate(,)-|",
-		"[HTTP:method:*]-replace{HTTP/1.1:value:1}-|",
-		"[HTTP:method:*]-insert{%09:end:value:1}-|",
-		"[HTTP:method:*]-insert{%09:start:value:1}-|",
-		"[HTTP:method:*]-insert{%0A:start:value:1}-|",
-		"[HTTP:method:*]-insert{%0B:end:value:1}-|",
-		"[HTTP:method:*]-insert{%0D:end:value:2}-|",
-		"[HTTP:version:*]-insert{%0A%09%0A%09:end:value:1}-|",
-		"[HTTP:version:*]-insert{%0A%09:end:value:1}-|",
-		"[HTTP:version:*]-insert{%0A%20%0A%20:end:value:1}-|",
-		"[HTTP:version:*]-insert{%20%0A%09:end:value:1}-|",
-		"[HTTP:host:*]-duplicate(replace{%C3%97:name:596},insert{%20:end:name:786})-|",
-		"[HTTP:host:*]-replace{%5E:name:926}(duplicate(duplicate(,replace{host:name:1}(insert{%20:start:value:3238},)),),)-|",
-		"[HTTP:host:*]-replace{%C3%97:name:1358}(duplicate(duplicate(,replace{host:name:1}(insert{%20:end:value},)),),)-|",
-		"[HTTP:host:*]-insert{%20:end:value:4081}(duplicate(duplicate(,replace{a:name:1}),insert{%09:start:name:3238}),)-|",
-		"[HTTP:host:*]-insert{%20:end:value:4081}(duplicate(duplicate(insert{%09:start:name:3238},),replace{a:name:1}),)-|",
-	},
+		strategyEntries(CensorKeyword, []StrategyEntry{
+			{Strategy: "[HTTP:version:*]-insert{%09:middle:value:14}-|"},
+			{Strategy: "[HTTP:path:*]-insert{%09:end:value:1434}-|[HTTP:path:*]-insert{1:start:value:507}-|"},
+			{Strategy: "[HTTP:path:*]-insert{%20:end:value:1}-|[HTTP:path:*]-insert{g:end:value:1013}-|"},
+			{Strategy: "[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:host:*]-duplicate(replace{/:name:64}(replace{/?ultrasurf:value},),)-|"},
+			{Strategy: "[HTTP:host:*]-duplicate(replace{a:name:64},)-|"},
+			{Strategy: "[HTTP:method:*]-insert{%20:end:value:1}-|[HTTP:host:*]-duplicate(replace{%2F:name:64},)-|"},
+			{Strategy: "[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:host:*]-duplicate(replace{%C2%B0:name:32},)-|"},
+			{Strategy: "[HTTP:method:*]-insert{%0A:start:value:4336}-|"},
+			{Strategy: "[HTTP:path:*]-insert{%0D:end:value:1434}-|"},
+			{Strategy: "[HTTP:path:*]-insert{%20:start:value:1}-|[HTTP:path:*]-replace{3:value:511}(insert{&:start:value},)-|"},
+			{Strategy: "[HTTP:version:*]-insert{%25:middle:value:1434}-|"},
+			{Strategy: "[HTTP:version:*]-insert{%C3%8B:middle:value:717}-|"},
+			{Strategy: "[HTTP:method:*]-replace{%3A:value:1}-|", Notes: noteMethodReplaced},
+			{Strategy: "[HTTP:method:*]-replace{HTTP/1.1:value:1}-|", Notes: noteMethodReplaced},
+			{Strategy: "[HTTP:path:*]-duplicate(insert{3:middle:value:1004},replace{&ultrasurf:value})-|"},
+			{Strategy: "[HTTP:method:*]-insert{%0D:end:value:2}-|"},
+			{Strategy: "[HTTP:path:*]-insert{%0D:start:value:1}-|"},
+			{Strategy: "[HTTP:host:*]-duplicate(replace{%C3%97:name:596},insert{%20:end:name:786})-|"},
+			{Strategy: "[HTTP:host:*]-replace{%5E:name:926}(duplicate(duplicate(,replace{host:name:1}(insert{%20:start:value:3238},)),),)-|"},
+			{Strategy: "[HTTP:host:*]-replace{%C3%97:name:1358}(duplicate(duplicate(,replace{host:name:1}(insert{%20:end:value},)),),)-|"},
+			{Strategy: "[HTTP:host:*]-replace{%C3%97:name:1371}(duplicate(duplicate(,replace{host:name:1}),),)-|"},
+			{Strategy: "[HTTP:host:*]-insert{%20:end:value:4081}(duplicate(duplicate(,replace{a:name:1}),insert{%09:start:name:3238}),)-|"},
+			{Strategy: "[HTTP:host:*]-insert{%20:end:value:4081}(duplicate(duplicate(insert{%09:start:name:3238},),replace{a:name:1}),)-|"},
+			{Strategy: "[HTTP:host:*]-replace{PUT:name:423}(duplicate(duplicate(,replace{host:name}),),)-|"},
+			{Strategy: "[HTTP:version:*]-replace{OPTIONS:value:1}-|", Notes: noteVersionReplaced},
+		})...,
+	),
+	"India": strategyEntries(CensorUnknown, []StrategyEntry{
+		{Strategy: "[HTTP:host:*]-changecase{lower}-|"},
+		{Strategy: "[HTTP:host:*]-changecase{upper}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%09:middle:value:14}-|"},
+		{Strategy: "[HTTP:path:*]-insert{%09:end:value:1434}-|[HTTP:path:*]-insert{1:start:value:507}-|"},
+		{Strategy: "[HTTP:path:*]-insert{%20:end:value:1}-|[HTTP:path:*]-insert{g:end:value:1013}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%09:end:value}-|[HTTP:host:*]-duplicate(replace{a:name:64},)-|"},
+		{Strategy: "[HTTP:method:*]-insert{%0A:start:value:1}-|[HTTP:host:*]-duplicate(replace{%2F:name:64},)-|"},
+		{Strategy: "[HTTP:host:*]-duplicate(insert{%0A:end:value:1},)-|"},
+		{Strategy: "[HTTP:host:*]-duplicate(insert{%0A:random:name:1},)-|"},
+		{Strategy: "[HTTP:host:*]-duplicate(insert{%20%0A:end:name:1},)-|"},
+		{Strategy: "[HTTP:host:*]-insert{%09:end:name}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%0A%0A:start:value:1}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%0A%20:start:value:1}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%0A:end:value:1}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%20%0A:start:name:1}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%20:end:name:1}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%20:start:name:1}-|"},
+		{Strategy: "[HTTP:path:*]-replace{/:value:1434}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%20:start:value:1413}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%20:start:value:1434}-|"},
+		{Strategy: "[HTTP:method:*]-duplicate(,replace{a:name:1407})-|"},
+		{Strategy: "[HTTP:method:*]-insert{%09:end:value:2568}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%0A:start:value:4336}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%20:end:value:1413}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%20:end:value:1720}-|"},
+		{Strategy: "[HTTP:path:*]-duplicate(replace{a:name:1}(insert{a:start:value:1408},),)-|"},
+		{Strategy: "[HTTP:path:*]-insert{%0D:end:value:1434}-|"},
+		{Strategy: "[HTTP:path:*]-insert{%20:end:value:1413}-|"},
+		{Strategy: "[HTTP:path:*]-insert{%23:end:value:1413}-|"},
+		{Strategy: "[HTTP:path:*]-insert{%23:end:value:1}(insert{%C3:end:value:470},)-|"},
+		{Strategy: "[HTTP:path:*]-insert{%3F:end:value:1413}-|"},
+		{Strategy: "[HTTP:path:*]-insert{%3F:start:value:1413}-|"},
+		{Strategy: "[HTTP:path:*]-replace{/:value:1414}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%20:end:value:1434}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%20:start:value:1434}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%25:middle:value:1434}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%C2%81:end:value:773}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%C3%8B:middle:value:717}-|"},
+		{Strategy: "[HTTP:method:*]-replace{%3A:value:1}-|", Notes: noteMethodReplaced},
+		{Strategy: "[HTTP:method:*]-duplicate(,)-|"},
+		{Strategy: "[HTTP:method:*]-replace{HTTP/1.1:value:1}-|", Notes: noteMethodReplaced},
+		{Strategy: "[HTTP:path:*]-duplicate(insert{3:middle:value:1004},replace{&ultrasurf:value})-|"},
+		{Strategy: "[HTTP:path:*]-insert{%3F:start:value:1}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%09:end:value:1}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%09:start:value:1}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%0A:start:value:1}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%0B:end:value:1}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%0D:end:value:2}-|"},
+		{Strategy: "[HTTP:path:*]-insert{%09:end:value:1}-|"},
+		{Strategy: "[HTTP:path:*]-insert{%09:start:value:1}-|"},
+		{Strategy: "[HTTP:path:*]-insert{%0C:start:value:1}-|"},
+		{Strategy: "[HTTP:path:*]-insert{%0D:start:value:1}-|"},
+		{Strategy: "[HTTP:path:*]-insert{%20:end:value:1}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%0A%09%0A%09:end:value:1}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%0A%20%0A%20:end:value:1}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%20%0A%09:end:value:1}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%20:end:value:1}-|"},
+		{Strategy: "[HTTP:host:*]-duplicate(replace{%C3%97:name:596},insert{%20:end:name:786})-|"},
+		{Strategy: "[HTTP:host:*]-replace{%C3%97:name:1358}(duplicate(duplicate(,replace{host:name:1}(insert{%20:end:value},)),),)-|"},
+		{Strategy: "[HTTP:host:*]-replace{%C3%97:name:1371}(duplicate(duplicate(,replace{host:name:1}),),)-|"},
+		{Strategy: "[HTTP:host:*]-replace{PUT:name:423}(duplicate(duplicate(,replace{host:name}),),)-|"},
+		{Strategy: "[HTTP:version:*]-replace{OPTIONS:value:1}-|", Notes: noteVersionReplaced},
+		{Strategy: "[HTTP:version:*]-duplicate-|"},
+	}),
+	"Kazakhstan": strategyEntries(CensorUnknown, []StrategyEntry{
+		{Strategy: "[HTTP:method:*]-insert{%09:end:value}-|[HTTP:host:*]-duplicate(replace{a:name:64},)-|"},
+		{Strategy: "[HTTP:method:*]-insert{%0A:start:value:1}-|[HTTP:host:*]-duplicate(replace{%2F:name:64},)-|"},
+		{Strategy: "[HTTP:host:*]-insert{%09:end:name}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%09:end:value:1}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%09:start:value:1}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%0A%0A:start:value:1}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%0A%20:start:value:1}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%20%0A:start:name:1}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%20:end:name:1}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%20:end:value:1}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%20:start:name:1}-|"},
+		{Strategy: "[HTTP:host:*]-insert{%20:start:value:1434}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%09:end:value:2568}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%0A:start:value:4336}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%20:end:value:1720}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%20:end:value:1434}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%20:start:value:1434}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%25:middle:value:1434}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%C2%81:end:value:773}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%C3%8B:middle:value:717}-|"},
+		{Strategy: "[HTTP:method:*]-replace{%3A:value:1}-|", Notes: noteMethodReplaced},
+		{Strategy: "[HTTP:method:*]-duplicate(,)-|"},
+		{Strategy: "[HTTP:method:*]-replace{HTTP/1.1:value:1}-|", Notes: noteMethodReplaced},
+		{Strategy: "[HTTP:method:*]-insert{%09:end:value:1}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%09:start:value:1}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%0A:start:value:1}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%0B:end:value:1}-|"},
+		{Strategy: "[HTTP:method:*]-insert{%0D:end:value:2}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%0A%09%0A%09:end:value:1}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%0A%09:end:value:1}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%0A%20%0A%20:end:value:1}-|"},
+		{Strategy: "[HTTP:version:*]-insert{%20%0A%09:end:value:1}-|"},
+		{Strategy: "[HTTP:host:*]-duplicate(replace{%C3%97:name:596},insert{%20:end:name:786})-|"},
+		{Strategy: "[HTTP:host:*]-replace{%5E:name:926}(duplicate(duplicate(,replace{host:name:1}(insert{%20:start:value:3238},)),),)-|"},
+		{Strategy: "[HTTP:host:*]-replace{%C3%97:name:1358}(duplicate(duplicate(,replace{host:name:1}(insert{%20:end:value},)),),)-|"},
+		{Strategy: "[HTTP:host:*]-insert{%20:end:value:4081}(duplicate(duplicate(,replace{a:name:1}),insert{%09:start:name:3238}),)-|"},
+		{Strategy: "[HTTP:host:*]-insert{%20:end:value:4081}(duplicate(duplicate(insert{%09:start:name:3238},),replace{a:name:1}),)-|"},
+	}),
+}
+
+// Strategies is a map of geneva strategies keyed to the country they were found to work in. It holds the same
+// strategies, in the same order, as StrategiesDetailed, without the metadata.
+var Strategies = strategyStrings(StrategiesDetailed)
+
+// CensorKind is the kind of censorship a strategy was found to evade.
+type CensorKind int
+
+const (
+	// CensorUnknown means the kind of censorship is not recorded.
+	CensorUnknown CensorKind = iota
+	// CensorHostname is censorship based on the requested hostname.
+	CensorHostname
+	// CensorKeyword is censorship based on keywords in the request.
+	CensorKeyword
+)
+
+// String returns the name of the kind of censorship.
+func (k CensorKind) String() string {
+	switch k {
+	case CensorHostname:
+		return "hostname"
+	case CensorKeyword:
+		return "keyword"
+	default:
+		return "unknown"
+	}
+}
+
+// StrategyEntry is a strategy in Strategies along with metadata about it.
+type StrategyEntry struct {
+	// Strategy is the strategy in Geneva syntax.
+	Strategy string
+	// CensorKind is the kind of censorship the strategy was found to evade.
+	CensorKind CensorKind
+	// Notes is free-form information about the strategy. It is empty if there is none.
+	Notes string
+}
+
+// Notes about the effect of a strategy that consumers should know about.
+const (
+	// noteMethodReplaced notes that a strategy replaces the method, which NormalizeRequest can't recover, so it uses
+	// the default method instead.
+	noteMethodReplaced = "replaces the method, which can't be recovered when normalizing"
+	// noteVersionReplaced notes that a strategy replaces the version, which NormalizeRequest can't recover, so it uses
+	// the default version instead.
+	noteVersionReplaced = "replaces the version, which can't be recovered when normalizing"
+)
+
+// strategyEntries sets the CensorKind of each of entries to kind and returns them.
+func strategyEntries(kind CensorKind, entries []StrategyEntry) []StrategyEntry {
+	for i := range entries {
+		entries[i].CensorKind = kind
+	}
+
+	return entries
+}
+
+// strategyStrings returns the strategies of each country in detailed without their metadata.
+func strategyStrings(detailed map[string][]StrategyEntry) map[string][]string {
+	strategies := make(map[string][]string, len(detailed))
+	for country, entries := range detailed {
+		ss := make([]string, len(entries))
+		for i, e := range entries {
+			ss[i] = e.Strategy
+		}
+
+		strategies[country] = ss
+	}
+
+	return strategies
 }
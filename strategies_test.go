@@ -0,0 +1,59 @@
+package algeneva
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrategies(t *testing.T) {
+	// Strategies must keep every strategy it had before it was derived from StrategiesDetailed.
+	assert.Len(t, Strategies, 3)
+	assert.Len(t, Strategies["China"], 55)
+	assert.Len(t, Strategies["India"], 63)
+	assert.Len(t, Strategies["Kazakhstan"], 37)
+	assert.Equal(t, "[HTTP:version:*]-insert{%09:middle:value:14}-|", Strategies["China"][0])
+	assert.Equal(t, "[HTTP:version:*]-replace{OPTIONS:value:1}-|", Strategies["China"][54])
+}
+
+func TestStrategiesDetailed(t *testing.T) {
+	// the hostname censor strategies for China come before the keyword censor strategies.
+	china := StrategiesDetailed["China"]
+	for i, e := range china {
+		want := CensorHostname
+		if i >= 30 {
+			want = CensorKeyword
+		}
+
+		assert.Equal(t, want, e.CensorKind, "China[%d]", i)
+	}
+
+	assert.Equal(t, CensorUnknown, StrategiesDetailed["India"][0].CensorKind)
+	assert.Equal(t, "keyword", CensorKeyword.String())
+
+	// the notes must match what the strategy does.
+	for country, entries := range StrategiesDetailed {
+		for i, e := range entries {
+			s, err := NewHTTPStrategy(e.Strategy)
+			require.NoError(t, err, "%s[%d]", country, i)
+
+			var notes []string
+			for _, r := range s.rules {
+				if _, ok := r.tree.(*replaceAction); !ok {
+					continue
+				}
+
+				switch r.trigger.targetField {
+				case "method":
+					notes = append(notes, noteMethodReplaced)
+				case "version":
+					notes = append(notes, noteVersionReplaced)
+				}
+			}
+
+			assert.Equal(t, strings.Join(notes, "; "), e.Notes, "%s[%d]", country, i)
+		}
+	}
+}
@@ -1,8 +1,14 @@
 package algeneva
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"net/textproto"
+	"net/url"
 	"strings"
 )
 
@@ -13,17 +19,49 @@ var (
 	ErrInvalidAction = errors.New("invalid action")
 )
 
+// Strategy is a Geneva strategy that can be applied to a request. Strategy is protocol agnostic so that HTTP and,
+// once supported, DNS strategies can be used interchangeably.
+type Strategy interface {
+	// Apply applies the strategy to req and returns the modified request.
+	Apply(req []byte) ([]byte, error)
+}
+
 // HTTPStrategy is a series of Geneva rules to be applied to a request.
 type HTTPStrategy struct {
+	// Atomic reports whether Apply should return the original request and an error if the modified request can no
+	// longer be parsed as an HTTP request, such as when the version is replaced, rather than the modified request.
+	Atomic bool
+	// StableRandom reports whether an insert action with a random location should use the same location in every
+	// copy of a field made by a duplicate action. A new location is still chosen each time a rule is applied.
+	StableRandom bool
+	// ConnectAuthority reports whether rules targeting the host header should be applied to the request-target of a
+	// CONNECT request instead. The request-target of a CONNECT request is in authority-form, e.g. example.com:443,
+	// and is what the proxy connects to, while the Host header, if there is one, is not used for routing. The trigger
+	// is matched against the whole request-target, and actions applied to the value component modify it, as if the
+	// rule targeted the path. Since the request-target is always present, a trigger for an absent host never matches.
+	ConnectAuthority bool
+
 	rules []rule
 }
 
 // NewHTTPStrategy constructs a HTTP Strategy from strategystr. strategystr consists of a series of rules separated by
 // '|'. Each rule is formatted as '<trigger>-<action>-|', rules must end with '-|'. An error is returned if
 // strategystr is not a valid strategy or is formatted incorrectly.
+//
+// Characters that are part of the syntax ('{', '}', '(', ')', ',', ':', '-', and '|') must be percent-encoded when
+// used in action values or trigger match strings, e.g. insert{%2C:start:value} inserts a literal ','. Values are
+// decoded after the strategy is parsed.
+//
+// Comments delimited by '/*' and '*/' can be placed before or between rules, or anywhere else outside of a trigger or
+// action arguments, and are removed before the strategy is parsed.
 func NewHTTPStrategy(strategystr string) (*HTTPStrategy, error) {
 	var rules []rule
 
+	strategystr, err := stripComments(strategystr)
+	if err != nil {
+		return nil, err
+	}
+
 	// Split the string into rules, which are separated by '|', and parse each rule.
 	parts := strings.SplitAfter(strategystr, "|")
 	switch {
@@ -49,6 +87,70 @@ func NewHTTPStrategy(strategystr string) (*HTTPStrategy, error) {
 	}, nil
 }
 
+// stripComments removes comments delimited by '/*' and '*/' from strategystr. Comments are only recognized outside of
+// triggers and action arguments, since '/*' can be part of a match string, e.g. [HTTP:path:/*], or a value. An error
+// is returned if a comment is not closed.
+func stripComments(strategystr string) (string, error) {
+	if !strings.Contains(strategystr, "/*") {
+		return strategystr, nil
+	}
+
+	var sb strings.Builder
+	var depth int
+	for i := 0; i < len(strategystr); i++ {
+		switch c := strategystr[i]; {
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == '/' && depth == 0 && strings.HasPrefix(strategystr[i:], "/*"):
+			end := strings.Index(strategystr[i+2:], "*/")
+			if end == -1 {
+				return "", fmt.Errorf("%w: %s, unclosed comment", ErrInvalidRule, strategystr)
+			}
+
+			i += end + 3
+			continue
+		}
+
+		sb.WriteByte(strategystr[i])
+	}
+
+	return sb.String(), nil
+}
+
+// LoadStrategies reads newline-delimited strategies from r and parses each one into an HTTPStrategy. Empty lines and
+// lines starting with '#' are skipped. LoadStrategies returns the successfully parsed strategies along with an error
+// for each line that failed to parse. An error reading from r is appended to the returned errors.
+func LoadStrategies(r io.Reader) ([]HTTPStrategy, []error) {
+	var (
+		strategies []HTTPStrategy
+		errs       []error
+	)
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		s, err := NewHTTPStrategy(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", n, err))
+			continue
+		}
+
+		strategies = append(strategies, *s)
+	}
+
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return strategies, errs
+}
+
 // string returns a string representation of the Strategy.
 func (s *HTTPStrategy) String() string {
 	var rules []string
@@ -59,33 +161,168 @@ func (s *HTTPStrategy) String() string {
 	return strings.Join(rules, "")
 }
 
+// TargetFields returns the distinct target fields of the strategy's rules in the order they first appear. This can
+// be used to decide whether the strategy is relevant to a request. Header names are lowercase.
+func (s *HTTPStrategy) TargetFields() []string {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, r := range s.rules {
+		if !seen[r.trigger.targetField] {
+			seen[r.trigger.targetField] = true
+			fields = append(fields, r.trigger.targetField)
+		}
+	}
+
+	return fields
+}
+
+// EstimateExpansion returns the worst-case number of bytes the strategy adds to a request, computed from the values
+// and number of copies of the insert and replace actions. Replaced content is assumed to be empty. The size of
+// fields copied by duplicate actions depends on the request and is not included, but values inserted before a
+// duplicate are counted once for each copy. Likewise, a value that references a variable, such as $host, is counted as
+// 0 bytes since it expands to part of the request.
+func (s *HTTPStrategy) EstimateExpansion() int {
+	var total int
+	for _, r := range s.rules {
+		added, _ := expansion(r.tree)
+		total += added
+	}
+
+	return total
+}
+
+// expansion returns the worst-case number of bytes added by the action tree a and the number of fields it produces.
+func expansion(a action) (added, fields int) {
+	switch a := a.(type) {
+	case *changecaseAction:
+		return expansion(a.next)
+	case *customAction:
+		// the size of the value returned by a custom action isn't known, so only the actions after it are counted.
+		return expansion(a.next)
+	case *insertAction:
+		added, fields = expansion(a.next)
+		return added + len(a.value)*fields, fields
+	case *replaceAction:
+		added, fields = expansion(a.next)
+		return added + len(a.value)*fields, fields
+	case *duplicateAction:
+		la, lf := expansion(a.leftAction)
+		ra, rf := expansion(a.rightAction)
+		return la + ra, lf + rf
+	default:
+		return 0, 1
+	}
+}
+
+// SetRuleEnabled enables or disables the rule at index, in the order the rules appear in the strategy string. A
+// disabled rule is skipped when the strategy is applied, but is still part of the strategy, e.g. it is included in
+// String. SetRuleEnabled panics if index is out of range, and must not be called concurrently with applying the
+// strategy.
+func (s *HTTPStrategy) SetRuleEnabled(index int, enabled bool) {
+	s.rules[index].disabled = !enabled
+}
+
 // Apply applies the strategy to the input HTTP request. An error is returned
 // if the input does not represent an HTTP request. The input does not need to
 // include the body, but must include the start-line and all header lines. The
 // body may be included, in which case it will be included in the return value,
-// unmodified.
+// unmodified. If there is no body, the empty line ending the headers can be
+// omitted, but it is always included in the return value.
 func (s *HTTPStrategy) Apply(req []byte) ([]byte, error) {
+	return s.ApplyContext(context.Background(), req)
+}
+
+// ApplyContext is like Apply, but checks ctx before each rule is applied and stops, returning the original request and
+// the context's error, if ctx is done. This bounds how long a strategy with many rules or large values can run.
+func (s *HTTPStrategy) ApplyContext(ctx context.Context, req []byte) ([]byte, error) {
 	r, err := newRequest(req)
 	if err != nil {
 		return req, err
 	}
 
-	s.apply(r)
-	return r.bytes(), nil
+	if err := s.apply(ctx, r); err != nil {
+		return req, err
+	}
+
+	modified := r.bytes()
+	if s.Atomic {
+		if _, err := newRequest(modified); err != nil {
+			return req, fmt.Errorf("strategy produced an invalid request: %w", err)
+		}
+	}
+
+	return modified, nil
 }
 
-// apply applies the strategy to the request.
-func (s *HTTPStrategy) apply(req *request) {
-	// iterate over each rule and if the trigger matches, apply the action tree to the target field.
-	for _, r := range s.rules {
-		if fld, match := r.trigger.match(req); match {
-			// apply the action tree to the target field.
-			// since the duplicate action can cause the tree to branch, the modifications are returned as a slice of
-			// Fields which need to be applied to the request.
-			mods := r.apply(fld)
-			// apply the modifications to the request.
-			applyModifications(req, fld, mods)
+// apply applies the strategy to the request. An error is returned if any of the actions fail or if ctx is done.
+func (s *HTTPStrategy) apply(ctx context.Context, req *request) error {
+	match := func(r *rule) (field, bool) {
+		matcher := r.trigger.match
+		if s.ConnectAuthority && r.trigger.targetField == "host" && strings.EqualFold(req.method, "CONNECT") {
+			matcher = r.trigger.matchConnectAuthority
+		}
+
+		fld, ok := matcher(req)
+		if ok {
+			fld.vars = requestVars(req)
 		}
+
+		return fld, ok
+	}
+
+	return s.applyRules(ctx, match, func(fld field, mods []field) {
+		applyModifications(req, fld, mods)
+	})
+}
+
+// applyRules applies each enabled rule whose trigger matches to a message, stopping if ctx is done. match returns the
+// target field of a rule and whether its trigger matched. Since the duplicate action can cause the action tree to
+// branch, the modifications are returned as a slice of fields, which modify applies to the message along with the
+// original field.
+func (s *HTTPStrategy) applyRules(
+	ctx context.Context,
+	match func(r *rule) (field, bool),
+	modify func(fld field, mods []field),
+) error {
+	for i := range s.rules {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		r := &s.rules[i]
+		if r.disabled {
+			continue
+		}
+
+		fld, ok := match(r)
+		if !ok {
+			continue
+		}
+
+		if s.StableRandom {
+			// the seed must not be zero, which means no seed.
+			fld.randSeed = rand.Uint64() | 1
+		}
+
+		mods, err := r.apply(fld)
+		if err != nil {
+			return fmt.Errorf("failed to apply rule %s: %w", r.string(), err)
+		}
+
+		modify(fld, mods)
+	}
+
+	return nil
+}
+
+// requestVars returns the current values of the variables that action values can reference in req. See
+// actionVariables.
+func requestVars(req *request) map[string]string {
+	_, host, _ := strings.Cut(req.getHeader("host"), ":")
+	return map[string]string{
+		"host":   strings.Trim(host, " \t"),
+		"method": req.method,
+		"path":   req.path,
 	}
 }
 
@@ -95,15 +332,17 @@ type rule struct {
 	trigger trigger
 	// tree is the action tree to be applied to the target field if the trigger is met.
 	tree action
+	// disabled is true if the rule was disabled with SetRuleEnabled and is skipped when the strategy is applied.
+	disabled bool
 }
 
 // string returns a string representation of the Rule.
 func (r *rule) string() string {
-	return fmt.Sprintf("%s-%s-|", r.trigger.string(), r.tree.string())
+	return fmt.Sprintf("%s-%s-|", r.trigger.String(), r.tree.string())
 }
 
 // apply applies the Tree to the field.
-func (r *rule) apply(f field) []field {
+func (r *rule) apply(f field) ([]field, error) {
 	return r.tree.apply(f)
 }
 
@@ -111,25 +350,117 @@ func (r *rule) apply(f field) []field {
 type trigger struct {
 	// proto is the protocol of the request.
 	proto string
-	// targetField is the field to apply actions.
+	// targetField is the field to apply actions. It can be the method, path, or version, a header name, or the scheme
+	// or authority of a path in absolute-form. It can also be query.<name>, the value of the first query parameter of
+	// the path named name, which is compared case-insensitively. The value is matched and modified as it is in the
+	// path, without decoding it, and a parameter without a '=' has no value to match.
+	// A header named scheme, authority, or query.<name> is still matched if the path is not in absolute-form or has no
+	// such parameter, so triggers that targeted those headers before the pseudo-fields were added keep working for
+	// most requests. They can't be targeted by absent or joined triggers, though.
 	targetField string
-	// matchStr is the value Field needs to be to match. If matchStr is '*', then the trigger will always match.
+	// matchStr is the value Field needs to be to match. If matchStr is '*', then the trigger will always match. A '*'
+	// within matchStr matches any sequence of characters, e.g. *.example.com.
+	// matchStr is percent-decoded, so it can contain ':' by encoding it as %3A.
 	matchStr string
+	// glob holds the parts of matchStr separated by '*' if matchStr contains a '*' and is not only '*'. Each '*'
+	// matches any sequence of characters. glob is nil otherwise.
+	glob []string
+	// absent is true if the trigger matches when the target header is missing from the request. It is set by
+	// prefixing the target field with '!'. matchStr is ignored if absent is true.
+	absent bool
+	// joined is true if matchStr is matched against the values of every occurrence of the target header joined with
+	// ", " instead of only the first occurrence. It is set by prefixing the target field with '+'. Actions are still
+	// applied to the first occurrence.
+	joined bool
+	// decoded is true if the value of the target field is percent-decoded before it's matched against matchStr, e.g.
+	// so [HTTP:~host:example.com] matches a host of %65xample.com. It is set by prefixing the target field with '~',
+	// after any '+'. Values with invalid escapes are matched as they are.
+	decoded bool
 }
 
-// string returns a string representation of the Trigger.
-func (t *trigger) string() string {
-	return fmt.Sprintf("[%s:%s:%s]", strings.ToUpper(t.proto), t.targetField, t.matchStr)
+// targetsHeader reports whether the target field of the trigger is a header rather than a part of the start line.
+func (t trigger) targetsHeader() bool {
+	switch t.targetField {
+	case "method", "path", "version", "scheme", "authority":
+		return false
+	}
+
+	return !strings.HasPrefix(t.targetField, queryFieldPrefix)
+}
+
+// queryFieldPrefix is the prefix of target fields that are query parameters, e.g. query.utm_source.
+const queryFieldPrefix = "query."
+
+// String returns a string representation of the Trigger in Geneva syntax.
+func (t trigger) String() string {
+	fld := t.targetField
+	if t.absent {
+		fld = "!" + fld
+	}
+
+	if t.decoded {
+		fld = "~" + fld
+	}
+
+	if t.joined {
+		fld = "+" + fld
+	}
+
+	return fmt.Sprintf("[%s:%s:%s]", strings.ToUpper(t.proto), fld, matchStrEncoder.Replace(t.matchStr))
 }
 
+// matchStrEncoder percent-encodes the characters that are part of the syntax, and '%' itself, so that a decoded match
+// string is written as one that parses to the same value.
+var matchStrEncoder = strings.NewReplacer(
+	"%", "%25",
+	":", "%3A",
+	"-", "%2D",
+	"|", "%7C",
+	"[", "%5B",
+	"]", "%5D",
+	"{", "%7B",
+	"}", "%7D",
+	"(", "%28",
+	")", "%29",
+	",", "%2C",
+)
+
 // match returns whether the value of TargetField of req matches MatchStr. If true, the target field is returned
 // as a Field.
+// If the trigger matches an absent header, match returns true only if the header is missing. The returned Field is
+// then an empty header with the canonical name of TargetField, which actions can modify to add the header.
 // Since DNS and DNSQR are not supported yet, Proto is ignored, except if it is empty, in which case it will fail.
 func (t *trigger) match(req *request) (field, bool) {
 	if t.proto == "" {
 		return field{}, false
 	}
 
+	if t.absent {
+		if req.getHeader(t.targetField) != "" {
+			return field{}, false
+		}
+
+		return field{
+			name:     textproto.CanonicalMIMEHeaderKey(t.targetField),
+			ows:      " ",
+			isHeader: true,
+		}, true
+	}
+
+	if strings.HasPrefix(t.targetField, queryFieldPrefix) {
+		start, end, ok := findQueryParam(req.path, t.targetField[len(queryFieldPrefix):])
+		if !ok {
+			return t.matchHeader(req)
+		}
+
+		fld := field{
+			name:  t.targetField,
+			value: req.path[start:end],
+		}
+
+		return fld, t.matchValue(fld.value)
+	}
+
 	var fld field
 	switch t.targetField {
 	case "method":
@@ -147,32 +478,119 @@ func (t *trigger) match(req *request) (field, bool) {
 			name:  "version",
 			value: req.version,
 		}
-	default:
-		// the target field is a header. find it and parse it into a Field.
-		header := req.getHeader(t.targetField)
-		if header == "" {
-			return field{}, false
+	case "scheme", "authority":
+		// the scheme and authority are only part of the path if it's in absolute-form.
+		scheme, authority, _, ok := splitAbsoluteForm(req.path)
+		if !ok {
+			return t.matchHeader(req)
 		}
 
-		parts := strings.Split(header, ":")
 		fld = field{
-			name:     parts[0],
-			value:    parts[1],
-			isHeader: true,
+			name:  t.targetField,
+			value: scheme,
 		}
+		if t.targetField == "authority" {
+			fld.value = authority
+		}
+	default:
+		return t.matchHeader(req)
 	}
 
-	return fld, matchValue(fld.value, t.matchStr)
+	return fld, t.matchValue(fld.value)
 }
 
-func matchValue(value, matchstr string) bool {
-	return matchstr == "*" || value == matchstr
+// matchHeader returns whether the value of the first header named TargetField matches MatchStr. If true, the header is
+// returned as a Field.
+func (t *trigger) matchHeader(req *request) (field, bool) {
+	header := req.getHeader(t.targetField)
+	if header == "" {
+		return field{}, false
+	}
+
+	name, value, _ := strings.Cut(header, ":")
+	trimmed := strings.TrimLeft(value, " \t")
+	ows := value[:len(value)-len(trimmed)]
+	value = strings.TrimRight(trimmed, " \t")
+	fld := field{
+		name:        name,
+		value:       value,
+		ows:         ows,
+		trailingOWS: trimmed[len(value):],
+		isHeader:    true,
+	}
+
+	if t.joined {
+		joined, _ := req.getJoinedHeader(t.targetField)
+		return fld, t.matchValue(joined)
+	}
+
+	// a host with a trailing dot, e.g. example.com., is the same host as without it, so it matches the same
+	// triggers. The dot is kept in the field so it isn't lost when the strategy is applied.
+	if t.targetField == "host" && !t.matchValue(fld.value) {
+		return fld, t.matchValue(trimHostDot(fld.value))
+	}
+
+	return fld, t.matchValue(fld.value)
+}
+
+// matchConnectAuthority reports whether the trigger matches the authority-form request-target of a CONNECT request,
+// returning it as the path so that modifications are applied to it. See HTTPStrategy.ConnectAuthority.
+func (t *trigger) matchConnectAuthority(req *request) (field, bool) {
+	if t.proto == "" || t.absent {
+		return field{}, false
+	}
+
+	fld := field{
+		name:  "path",
+		value: req.path,
+	}
+
+	// like the host header, an authority with a trailing dot matches the same triggers as without it.
+	return fld, t.matchValue(fld.value) || t.matchValue(trimHostDot(fld.value))
+}
+
+// matchValue reports whether value matches the match string of the trigger. The match string is lowercased when the
+// trigger is parsed, so values are matched case-insensitively. Otherwise, triggers such as [HTTP:method:GET] would
+// never match. If the trigger is decoded, value is percent-decoded first.
+func (t trigger) matchValue(value string) bool {
+	if t.matchStr == "*" {
+		return true
+	}
+
+	if t.decoded {
+		if v, err := url.PathUnescape(value); err == nil {
+			value = v
+		}
+	}
+
+	value = strings.ToLower(value)
+	if t.glob == nil {
+		return value == t.matchStr
+	}
+
+	first, last := t.glob[0], t.glob[len(t.glob)-1]
+	if len(value) < len(first)+len(last) || !strings.HasPrefix(value, first) || !strings.HasSuffix(value, last) {
+		return false
+	}
+
+	// the middle parts must appear in order between the first and last parts, without overlapping.
+	value = value[len(first) : len(value)-len(last)]
+	for _, part := range t.glob[1 : len(t.glob)-1] {
+		i := strings.Index(value, part)
+		if i == -1 {
+			return false
+		}
+
+		value = value[i+len(part):]
+	}
+
+	return true
 }
 
 // parseRule parses a string, rule, and returns a Rule. It returns an error if rule is not a valid rule or is
 // formatted incorrectly.
 func parseRule(r string) (rule, error) {
-	parts := strings.Split(r, "-")
+	parts := splitRule(r)
 
 	if len(parts) != 3 && parts[len(parts)-1] != "|" {
 		return rule{}, fmt.Errorf("%w: %s, should be formatted as '<trigger>-<actions>-|'", ErrInvalidRule, r)
@@ -194,6 +612,29 @@ func parseRule(r string) (rule, error) {
 	}, nil
 }
 
+// splitRule splits the rule r into its parts at each '-' that is not inside the arguments of an action, so that an
+// argument such as a negative number of copies reaches the action and is reported by it rather than breaking the rule
+// apart. A '-' in an action value or match string must still be percent-encoded.
+func splitRule(r string) []string {
+	var parts []string
+	var depth, start int
+	for i := 0; i < len(r); i++ {
+		switch r[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '-':
+			if depth == 0 {
+				parts = append(parts, r[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, r[start:])
+}
+
 // parseTrigger parses a string, trigger, and returns a Trigger. It returns an error if trigger is not a valid trigger
 // or is formatted incorrectly. A valid trigger is formatted as '[<proto>:<field>:<matchstr>]', where proto is the
 // protocol, field is the target field to apply actions, and matchstr is the string to match against.
@@ -220,12 +661,61 @@ func parseTrigger(str string) (trigger, error) {
 	}
 
 	fld := strings.ToLower(parts[1])
-	matchstr := strings.ToLower(parts[2][:len(parts[2])-1])
+
+	// a '!' prefix means the trigger matches if the header is absent, which only makes sense for headers.
+	absent := strings.HasPrefix(fld, "!")
+	if absent {
+		fld = fld[1:]
+		if fld == "" || !(trigger{targetField: fld}).targetsHeader() {
+			return trigger{}, fmt.Errorf("%w: %s, only headers can be matched as absent", ErrInvalidRule, str)
+		}
+	}
+
+	// a '+' prefix means the trigger matches against the joined values of all occurrences of the header, and a '~'
+	// prefix, after any '+', means the value of the field is percent-decoded before it's matched.
+	joined := strings.HasPrefix(fld, "+")
+	if joined {
+		fld = fld[1:]
+	}
+
+	decoded := strings.HasPrefix(fld, "~")
+	if decoded {
+		fld = fld[1:]
+	}
+
+	if (joined || decoded) && absent {
+		return trigger{}, fmt.Errorf("%w: %s, absent headers cannot be joined or decoded", ErrInvalidRule, str)
+	}
+
+	switch {
+	case fld == "" && (joined || decoded), fld == queryFieldPrefix:
+		return trigger{}, fmt.Errorf("%w: %s, missing target field", ErrInvalidRule, str)
+	case joined && !(trigger{targetField: fld}).targetsHeader():
+		return trigger{}, fmt.Errorf("%w: %s, only headers can be matched as joined", ErrInvalidRule, str)
+	}
+
+	// ':' separates the parts of the trigger, so it must be percent-encoded if it's in the match string. We decode
+	// it after splitting.
+	matchstr, err := url.PathUnescape(parts[2][:len(parts[2])-1])
+	if err != nil {
+		return trigger{}, fmt.Errorf("%w: invalid match string in trigger %s, %s", ErrInvalidRule, str, err)
+	}
+
+	matchstr = strings.ToLower(matchstr)
+
+	var glob []string
+	if matchstr != "*" && strings.Contains(matchstr, "*") {
+		glob = strings.Split(matchstr, "*")
+	}
 
 	return trigger{
 		proto:       proto,
 		targetField: fld,
 		matchStr:    matchstr,
+		glob:        glob,
+		absent:      absent,
+		joined:      joined,
+		decoded:     decoded,
 	}, nil
 }
 
@@ -305,21 +795,51 @@ func splitLeftRight(actionstr string) (string, string, error) {
 // applyModifications applies the modifications, mods, to the field in the request. fld is the original unmodified
 // field.
 func applyModifications(req *request, fld field, mods []field) {
-	// iterate over mods and construct the new value.
-	var newValue string
+	// iterate over mods and construct the new value. every branch of a duplicate action starts from a copy of fld and
+	// no action changes whether a field is a header, so the mods are all headers or all not. headers are each placed
+	// on their own line, while other fields are concatenated. a header whose name was replaced with an empty string is
+	// deleted, and the OWS on both sides is dropped from a header whose value was replaced with an empty string.
+	var sb strings.Builder
 	if fld.isHeader {
-		var vals []string
 		for _, mod := range mods {
-			vals = append(vals, mod.name+":"+mod.value)
+			if mod.name == "" {
+				continue
+			}
+
+			if sb.Len() > 0 {
+				sb.WriteString("\r\n")
+			}
+
+			sb.WriteString(mod.name + ":")
+			if mod.value != "" {
+				sb.WriteString(mod.ows + mod.value + mod.trailingOWS)
+			}
 		}
-
-		newValue = strings.Join(vals, "\r\n")
 	} else {
 		for _, mod := range mods {
-			newValue += mod.value
+			sb.WriteString(mod.value)
 		}
 	}
 
+	newValue := sb.String()
+
+	// a header can have the same name as a pseudo-field, such as scheme, so headers are handled first.
+	if fld.isHeader {
+		old := fld.name + ":" + fld.ows + fld.value + fld.trailingOWS
+		if newValue == "" {
+			// every copy of the header was deleted.
+			req.removeHeader(old)
+			return
+		}
+
+		// the header won't be found if the trigger matched an absent header, so we add it instead.
+		if !req.replaceHeader(old, newValue) {
+			req.addHeaderLine(newValue)
+		}
+
+		return
+	}
+
 	switch fld.name {
 	case "method":
 		req.method = newValue
@@ -327,8 +847,19 @@ func applyModifications(req *request, fld field, mods []field) {
 		req.path = newValue
 	case "version":
 		req.version = newValue
+	case "scheme", "authority":
+		scheme, authority, rest, _ := splitAbsoluteForm(req.path)
+		if fld.name == "scheme" {
+			scheme = newValue
+		} else {
+			authority = newValue
+		}
+
+		req.path = scheme + "://" + authority + rest
 	default:
-		h := fld.name + ":" + fld.value
-		req.headers = strings.Replace(req.headers, h, newValue, 1)
+		// the field is a query parameter.
+		if start, end, ok := findQueryParam(req.path, fld.name[len(queryFieldPrefix):]); ok {
+			req.path = req.path[:start] + newValue + req.path[end:]
+		}
 	}
 }
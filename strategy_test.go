@@ -1,6 +1,10 @@
 package algeneva
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -46,6 +50,618 @@ func TestNewStrategy(t *testing.T) {
 	}
 }
 
+type prefixStrategy string
+
+func (s prefixStrategy) Apply(req []byte) ([]byte, error) {
+	return append([]byte(s), req...), nil
+}
+
+func TestNewHTTPStrategy_NegativeNum(t *testing.T) {
+	for _, strategy := range []string{
+		"[HTTP:path:*]-insert{a:start:value:-5}-|",
+		"[HTTP:path:*]-replace{a:value:-1}-|",
+		"[HTTP:host:*]-duplicate(append{a:value:-2},)-|",
+	} {
+		t.Run(strategy, func(t *testing.T) {
+			_, err := NewHTTPStrategy(strategy)
+			assert.ErrorIs(t, err, ErrInvalidAction)
+			assert.ErrorContains(t, err, "number of copies must not be negative")
+		})
+	}
+}
+
+func TestNewHTTPStrategy_Comments(t *testing.T) {
+	want, err := NewHTTPStrategy(
+		"[HTTP:path:/*]-replace{/*a*/:value:1}-|[HTTP:host:*]-duplicate(insert{%20:start:name:1},)-|",
+	)
+	require.NoError(t, err)
+
+	got, err := NewHTTPStrategy(
+		"/* the path, which contains a wildcard */[HTTP:path:/*]-replace{/*a*/:value:1}-|" +
+			"/* duplicate host, /a/b/ */[HTTP:host:*]-duplicate(/* original */insert{%20:start:name:1},)-|/* end */",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = NewHTTPStrategy("/* unclosed [HTTP:host:*]-insert{%20:start:name:1}-|")
+	assert.ErrorIs(t, err, ErrInvalidRule)
+}
+
+func TestStrategy(t *testing.T) {
+	httpStrategy, err := NewHTTPStrategy("[HTTP:method:*]-changecase{lower}-|")
+	require.NoError(t, err)
+
+	req := []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	tests := []struct {
+		name     string
+		strategy Strategy
+		want     string
+	}{
+		{
+			name:     "HTTPStrategy",
+			strategy: httpStrategy,
+			want:     "get / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "custom strategy",
+			strategy: prefixStrategy("\r\n"),
+			want:     "\r\nGET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.strategy.Apply(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestLoadStrategies(t *testing.T) {
+	input := `# china hostname strategies
+[HTTP:host:*]-insert{%20:start:name:1}-|
+
+[HTTP:method:*]-insert{%20:end:value:1}-|[HTTP:host:*]-duplicate(replace{%2F:name:64},)-|
+[http:path:*]-changecase{upper}
+`
+	strategies, errs := LoadStrategies(strings.NewReader(input))
+	require.Len(t, strategies, 2)
+	assert.Len(t, strategies[0].rules, 1)
+	assert.Len(t, strategies[1].rules, 2)
+
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrInvalidRule)
+	assert.Contains(t, errs[0].Error(), "line 5")
+}
+
+func TestHTTPStrategy_TargetFields(t *testing.T) {
+	s, err := NewHTTPStrategy(
+		"[HTTP:method:*]-insert{%20:end:value:1}-|[HTTP:Host:*]-duplicate(replace{%2F:name:64},)-|" +
+			"[HTTP:method:*]-changecase{lower}-|",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"method", "host"}, s.TargetFields())
+}
+
+func TestHTTPStrategy_Apply(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		req      string
+		want     string
+	}{
+		{
+			name:     "replace scheme",
+			strategy: "[HTTP:scheme:http]-replace{https:value:1}-|",
+			req:      "GET http://example.com/some/path HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET https://example.com/some/path HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "changecase scheme",
+			strategy: "[HTTP:scheme:*]-changecase{upper}-|",
+			req:      "GET http://example.com?x=http://a.com HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET HTTP://example.com?x=http://a.com HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "insert into authority",
+			strategy: "[HTTP:authority:*]-insert{.:end:value:1}-|",
+			req:      "GET http://example.com/some/path HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET http://example.com./some/path HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "scheme of origin-form does not match",
+			strategy: "[HTTP:scheme:*]-replace{https:value:1}-|",
+			req:      "GET /some/path?x=http://a.com HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET /some/path?x=http://a.com HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "scheme header of origin-form",
+			strategy: "[HTTP:scheme:https]-replace{http:value}-|[HTTP:authority:*]-changecase{upper}-|",
+			req:      "GET /some/path HTTP/1.1\r\nscheme: https\r\nAuthority: example.com\r\n\r\n",
+			want:     "GET /some/path HTTP/1.1\r\nscheme: http\r\nAUTHORITY: EXAMPLE.COM\r\n\r\n",
+		}, {
+			name:     "scheme header of absolute-form",
+			strategy: "[HTTP:scheme:https]-replace{http:value}-|",
+			req:      "GET https://example.com/ HTTP/1.1\r\nScheme: https\r\n\r\n",
+			want:     "GET http://example.com/ HTTP/1.1\r\nScheme: https\r\n\r\n",
+		}, {
+			name:     "literal dollar",
+			strategy: "[HTTP:host:*]-insert{$:end:value}-|[HTTP:path:*]-replace{$foo:value}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET $foo HTTP/1.1\r\nHost: example.com$\r\n\r\n",
+		}, {
+			name:     "path trigger with padded start line",
+			strategy: "[HTTP:path:/]-insert{a:end:value}-|",
+			req:      "GET  /  HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET  /a  HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "insert at value start with OWS",
+			strategy: "[HTTP:host:*]-insert{XX:start:value}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: XXexample.com\r\n\r\n",
+		}, {
+			name:     "insert at value start without OWS",
+			strategy: "[HTTP:host:*]-insert{XX:start:value}-|",
+			req:      "GET / HTTP/1.1\r\nHost:example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost:XXexample.com\r\n\r\n",
+		}, {
+			name:     "method trigger",
+			strategy: "[HTTP:method:GET]-insert{%20:end:value:1}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET  / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "method trigger does not match",
+			strategy: "[HTTP:method:POST]-insert{%20:end:value:1}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "version trigger",
+			strategy: "[HTTP:version:HTTP/1.1]-changecase{lower}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET / http/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "HTTP/1.0 trigger on HTTP/1.0",
+			strategy: "[HTTP:version:HTTP/1.0]-insert{%20:end:value:1}-|",
+			req:      "GET / HTTP/1.0\r\nHost: example.com\r\n\r\n",
+			want:     "GET / HTTP/1.0 \r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "HTTP/1.0 trigger on HTTP/1.1",
+			strategy: "[HTTP:version:HTTP/1.0]-insert{%20:end:value:1}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "delete header name",
+			strategy: "[HTTP:host:*]-replace{:name:1}-|",
+			req:      "GET / HTTP/1.1\r\nAccept: */*\r\nHost: example.com\r\nA: b\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nAccept: */*\r\nA: b\r\n\r\n",
+		}, {
+			name:     "delete header value",
+			strategy: "[HTTP:host:*]-replace{:value:1}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost:\r\n\r\n",
+		}, {
+			name:     "delete one copy of duplicated header",
+			strategy: "[HTTP:host:*]-duplicate(,replace{:name:1})-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\nA: b\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: example.com\r\nA: b\r\n\r\n",
+		}, {
+			name:     "missing empty line",
+			strategy: "[HTTP:host:*]-insert{%20:start:name:1}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\n",
+			want:     "GET / HTTP/1.1\r\n Host: example.com\r\n\r\n",
+		}, {
+			name:     "changecase value keeps OWS",
+			strategy: "[HTTP:host:*]-changecase{upper}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHOST: EXAMPLE.COM\r\n\r\n",
+		}, {
+			name:     "changecase value keeps tab OWS",
+			strategy: "[HTTP:host:*]-changecase{upper}-|",
+			req:      "GET / HTTP/1.1\r\nHost:\t example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHOST:\t EXAMPLE.COM\r\n\r\n",
+		}, {
+			name:     "trigger matches value with trailing OWS",
+			strategy: "[HTTP:host:example.com]-insert{a:end:value}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com \t\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: example.coma \t\r\n\r\n",
+		}, {
+			name:     "duplicate keeps trailing OWS",
+			strategy: "[HTTP:host:*]-duplicate(,replace{a.com:value})-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com  \r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: example.com  \r\nHost: a.com  \r\n\r\n",
+		}, {
+			name:     "changecase value without OWS",
+			strategy: "[HTTP:host:*]-changecase{upper}-|",
+			req:      "GET / HTTP/1.1\r\nHost:example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHOST:EXAMPLE.COM\r\n\r\n",
+		}, {
+			name:     "duplicate keeps OWS of each copy",
+			strategy: "[HTTP:host:*]-duplicate(changecase{upper},insert{a.:start:value})-|",
+			req:      "GET / HTTP/1.1\r\nHost:  example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHOST:  EXAMPLE.COM\r\nHost:  a.example.com\r\n\r\n",
+		}, {
+			name:     "replace value keeps OWS",
+			strategy: "[HTTP:host:*]-replace{a.com:value}-|",
+			req:      "GET / HTTP/1.1\r\nHost:  example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost:  a.com\r\n\r\n",
+		}, {
+			name:     "insert encoded syntax characters",
+			strategy: "[HTTP:path:*]-duplicate(insert{%2C%7B:end:value},insert{%28%29%7D%2D%7C%3A:end:value})-|",
+			req:      "GET /a HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET /a,{/a()}-|: HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "match host with port",
+			strategy: "[HTTP:host:example.com%3A8080]-insert{a:end:value}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com:8080\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: example.com:8080a\r\n\r\n",
+		}, {
+			name:     "no match host with different port",
+			strategy: "[HTTP:host:example.com%3A8080]-insert{a:end:value}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com:443\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: example.com:443\r\n\r\n",
+		}, {
+			name:     "match host with trailing dot",
+			strategy: "[HTTP:host:example.com]-insert{a:start:value}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com.\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: aexample.com.\r\n\r\n",
+		}, {
+			name:     "match host with trailing dot and port",
+			strategy: "[HTTP:host:example.com%3A8080]-insert{a:end:value}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com.:8080\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: example.com.:8080a\r\n\r\n",
+		}, {
+			name:     "absent header is added",
+			strategy: "[HTTP:!host:*]-replace{example.com:value}-|",
+			req:      "GET / HTTP/1.1\r\nAccept: */*\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nAccept: */*\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "absent header without other headers",
+			strategy: "[HTTP:!host:*]-insert{example.com:start:value}-|",
+			req:      "GET / HTTP/1.1\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "absent header is present",
+			strategy: "[HTTP:!host:*]-replace{a.com:value}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "joined header matches later occurrence",
+			strategy: "[HTTP:+cookie:*b=2*]-insert{x:end:value}-|",
+			req:      "GET / HTTP/1.1\r\nCookie: a=1\r\nCookie: b=2\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nCookie: a=1x\r\nCookie: b=2\r\n\r\n",
+		}, {
+			name:     "joined header matches combined value",
+			strategy: "[HTTP:+cookie:a=1, b=2]-insert{x:end:value}-|",
+			req:      "GET / HTTP/1.1\r\nCookie: a=1\r\nCookie: b=2\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nCookie: a=1x\r\nCookie: b=2\r\n\r\n",
+		}, {
+			name:     "header without joined only matches first occurrence",
+			strategy: "[HTTP:cookie:*b=2*]-insert{x:end:value}-|",
+			req:      "GET / HTTP/1.1\r\nCookie: a=1\r\nCookie: b=2\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nCookie: a=1\r\nCookie: b=2\r\n\r\n",
+		}, {
+			name:     "decoded host matches escaped value",
+			strategy: "[HTTP:~host:example.com]-insert{a:end:value}-|",
+			req:      "GET / HTTP/1.1\r\nHost: %65xample.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: %65xample.coma\r\n\r\n",
+		}, {
+			name:     "escaped host does not match without decoding",
+			strategy: "[HTTP:host:example.com]-insert{a:end:value}-|",
+			req:      "GET / HTTP/1.1\r\nHost: %65xample.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: %65xample.com\r\n\r\n",
+		}, {
+			name:     "decoded path",
+			strategy: "[HTTP:~path:/a b]-insert{c:end:value}-|",
+			req:      "GET /a%20b HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET /a%20bc HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "decoded value with invalid escape is matched as is",
+			strategy: "[HTTP:~path:/a%25zz]-insert{c:end:value}-|",
+			req:      "GET /a%zz HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET /a%zzc HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "insert into query parameter",
+			strategy: "[HTTP:query.utm_source:*]-insert{%2520:end:value}-|",
+			req:      "GET /a?b=1&utm_source=news&c=2 HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET /a?b=1&utm_source=news%20&c=2 HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "match query parameter value",
+			strategy: "[HTTP:query.q:ultrasurf]-duplicate(,insert{x:start:value})-|",
+			req:      "GET /search?q=ultrasurf HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET /search?q=ultrasurfxultrasurf HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "missing query parameter",
+			strategy: "[HTTP:query.q:*]-replace{x:value}-|",
+			req:      "GET /search?p=1 HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET /search?p=1 HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "query header without query parameter",
+			strategy: "[HTTP:query.q:*]-replace{x:value}-|",
+			req:      "GET /search?p=1 HTTP/1.1\r\nQuery.Q: a\r\n\r\n",
+			want:     "GET /search?p=1 HTTP/1.1\r\nQuery.Q: x\r\n\r\n",
+		}, {
+			name:     "insert host variable into path",
+			strategy: "[HTTP:path:*]-insert{$host:end:value}-|",
+			req:      "GET /a? HTTP/1.1\r\nHost:  example.com \r\n\r\n",
+			want:     "GET /a?example.com HTTP/1.1\r\nHost:  example.com \r\n\r\n",
+		}, {
+			name:     "replace with copies of method variable",
+			strategy: "[HTTP:host:*]-replace{$method:value:2}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: GETGET\r\n\r\n",
+		}, {
+			name:     "variable uses the request as modified by earlier rules",
+			strategy: "[HTTP:path:*]-insert{b:end:value}-|[HTTP:host:*]-insert{$path:end:value}-|",
+			req:      "GET /a HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET /ab HTTP/1.1\r\nHost: example.com/ab\r\n\r\n",
+		}, {
+			name:     "missing host variable is empty",
+			strategy: "[HTTP:path:*]-insert{$host:end:value}-|",
+			req:      "GET /a HTTP/1.1\r\n\r\n",
+			want:     "GET /a HTTP/1.1\r\n\r\n",
+		}, {
+			name:     "encoded dollar sign is not a variable",
+			strategy: "[HTTP:path:*]-insert{%24host:end:value}-|",
+			req:      "GET /a HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET /a$host HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "version with inserted bytes",
+			strategy: "[HTTP:version:*]-insert{%20:end:value:2}-|",
+			req:      "GET / HTTP/1.1%09\r\nHost: example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1%09  \r\nHost: example.com\r\n\r\n",
+		}, {
+			name:     "mixed case header name with extra whitespace",
+			strategy: "[HTTP:host:*]-duplicate(,replace{a:name})-|",
+			req:      "GET / HTTP/1.1\r\nhOsT:  example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nhOsT:  example.com\r\na:  example.com\r\n\r\n",
+		}, {
+			name:     "header with whitespace before colon",
+			strategy: "[HTTP:host:*]-insert{a:end:value}-|",
+			req:      "GET / HTTP/1.1\r\nhOsT :\texample.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nhOsT :\texample.coma\r\n\r\n",
+		}, {
+			name:     "header name is a suffix of another header",
+			strategy: "[HTTP:host:*]-insert{a:end:value}-|",
+			req:      "GET / HTTP/1.1\r\nX-Host: example.com\r\nHost: example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nX-Host: example.com\r\nHost: example.coma\r\n\r\n",
+		}, {
+			name:     "duplicate header with replaced names",
+			strategy: "[HTTP:host:*]-replace{PUT:name:2}(duplicate(duplicate(,replace{host:name}),),)-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\nAccept: */*\r\n\r\n",
+			want: "GET / HTTP/1.1\r\nPUTPUT: example.com\r\nhost: example.com\r\nPUTPUT: example.com\r\n" +
+				"Accept: */*\r\n\r\n",
+		}, {
+			name:     "duplicate header with inserted value",
+			strategy: "[HTTP:host:*]-replace{%C3%97:name:1}(duplicate(duplicate(,replace{host:name:1}(insert{%20:end:value},)),),)-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\n×: example.com\r\nhost: example.com \r\n×: example.com\r\n\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewHTTPStrategy(tt.strategy)
+			require.NoError(t, err)
+
+			got, err := s.Apply([]byte(tt.req))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+type errAction struct{}
+
+func (a *errAction) string() string { return "fail" }
+
+func (a *errAction) apply(fld field) ([]field, error) {
+	return nil, errors.New("failed")
+}
+
+func TestHTTPStrategy_ApplyError(t *testing.T) {
+	s := &HTTPStrategy{
+		rules: []rule{
+			{
+				trigger: trigger{proto: "HTTP", targetField: "method", matchStr: "*"},
+				tree:    testChangecaseAction(),
+			}, {
+				trigger: trigger{proto: "HTTP", targetField: "host", matchStr: "*"},
+				tree:    newDuplicateAction(nil, &errAction{}),
+			},
+		},
+	}
+
+	req := []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	got, err := s.Apply(req)
+	assert.Error(t, err)
+	assert.Equal(t, req, got)
+}
+
+func TestHTTPStrategy_ApplyContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the first rule cancels the context, so the second rule must not be applied.
+	s := &HTTPStrategy{
+		rules: []rule{
+			{
+				trigger: trigger{proto: "HTTP", targetField: "method", matchStr: "*"},
+				tree: &customAction{
+					name: "cancel",
+					fn:   func(s string) (string, error) { cancel(); return s, nil },
+					next: &terminateAction{},
+				},
+			}, {
+				trigger: trigger{proto: "HTTP", targetField: "host", matchStr: "*"},
+				tree:    testChangecaseAction(),
+			},
+		},
+	}
+
+	req := []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	got, err := s.ApplyContext(ctx, req)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, req, got)
+
+	got, err = s.ApplyContext(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "GET / HTTP/1.1\r\nHOST: EXAMPLE.COM\r\n\r\n", string(got))
+}
+
+func TestHTTPStrategy_SetRuleEnabled(t *testing.T) {
+	s, err := NewHTTPStrategy("[HTTP:method:*]-changecase{lower}-|[HTTP:host:*]-changecase{upper}-|")
+	require.NoError(t, err)
+
+	req := []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	s.SetRuleEnabled(1, false)
+	got, err := s.Apply(req)
+	require.NoError(t, err)
+	assert.Equal(t, "get / HTTP/1.1\r\nHost: example.com\r\n\r\n", string(got))
+
+	s.SetRuleEnabled(0, false)
+	got, err = s.Apply(req)
+	require.NoError(t, err)
+	assert.Equal(t, string(req), string(got))
+
+	s.SetRuleEnabled(1, true)
+	got, err = s.Apply(req)
+	require.NoError(t, err)
+	assert.Equal(t, "GET / HTTP/1.1\r\nHOST: EXAMPLE.COM\r\n\r\n", string(got))
+
+	// disabled rules are still part of the strategy.
+	assert.Equal(t, "[HTTP:method:*]-changecase{lower}-|[HTTP:host:*]-changecase{upper}-|", s.String())
+	assert.Panics(t, func() { s.SetRuleEnabled(2, true) })
+}
+
+func TestHTTPStrategy_ApplyAtomic(t *testing.T) {
+	req := []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	for _, strategy := range []string{
+		"[HTTP:version:*]-replace{OPTIONS:value:1}-|",
+		"[HTTP:host:*]-insert{a:end:value:1}-|[HTTP:version:*]-insert{%09:middle:value:14}-|",
+	} {
+		t.Run(strategy, func(t *testing.T) {
+			s, err := NewHTTPStrategy(strategy)
+			require.NoError(t, err)
+
+			// the invalid request is returned by default.
+			got, err := s.Apply(req)
+			require.NoError(t, err)
+			assert.NotEqual(t, req, got)
+
+			s.Atomic = true
+			got, err = s.Apply(req)
+			assert.Error(t, err)
+			assert.Equal(t, req, got)
+		})
+	}
+
+	s, err := NewHTTPStrategy("[HTTP:host:*]-insert{a:end:value:1}-|")
+	require.NoError(t, err)
+
+	s.Atomic = true
+	got, err := s.Apply(req)
+	require.NoError(t, err)
+	assert.Equal(t, "GET / HTTP/1.1\r\nHost: example.coma\r\n\r\n", string(got))
+}
+
+func TestHTTPStrategy_ApplyStableRandom(t *testing.T) {
+	s, err := NewHTTPStrategy("[HTTP:host:*]-duplicate(insert{X:random:value:1},insert{X:random:value:1})-|")
+	require.NoError(t, err)
+
+	req := []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+
+	// applies the strategy n times and reports whether the two copies of the host header always matched.
+	same := func(n int) bool {
+		for i := 0; i < n; i++ {
+			got, err := s.Apply(req)
+			require.NoError(t, err)
+
+			r, err := newRequest(got)
+			require.NoError(t, err)
+
+			h0, h1, _ := strings.Cut(r.headers, "\r\n")
+			if h0 != h1 {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	// with 9 possible locations, the chance of 100 applications all choosing the same location for both copies is
+	// negligible.
+	assert.False(t, same(100))
+
+	s.StableRandom = true
+	assert.True(t, same(100))
+}
+
+func TestHTTPStrategy_ApplyConnectAuthority(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		req      string
+		want     string
+	}{
+		{
+			name:     "insert into authority",
+			strategy: "[HTTP:host:*]-insert{a:start:value}-|",
+			req:      "CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n",
+			want:     "CONNECT aexample.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n",
+		}, {
+			name:     "no host header",
+			strategy: "[HTTP:host:*]-insert{.:end:value}-|",
+			req:      "CONNECT example.com:443 HTTP/1.1\r\n\r\n",
+			want:     "CONNECT example.com:443. HTTP/1.1\r\n\r\n",
+		}, {
+			name:     "match authority",
+			strategy: "[HTTP:host:*.com%3A443]-changecase{upper}-|",
+			req:      "connect example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n",
+			want:     "connect EXAMPLE.COM:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n",
+		}, {
+			name:     "authority doesn't match",
+			strategy: "[HTTP:host:example.org%3A443]-changecase{upper}-|",
+			req:      "CONNECT example.com:443 HTTP/1.1\r\nHost: example.org:443\r\n\r\n",
+			want:     "CONNECT example.com:443 HTTP/1.1\r\nHost: example.org:443\r\n\r\n",
+		}, {
+			name:     "absent host never matches",
+			strategy: "[HTTP:!host:*]-replace{a:value}-|",
+			req:      "CONNECT example.com:443 HTTP/1.1\r\n\r\n",
+			want:     "CONNECT example.com:443 HTTP/1.1\r\n\r\n",
+		}, {
+			name:     "not connect",
+			strategy: "[HTTP:host:*]-insert{a:start:value}-|",
+			req:      "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			want:     "GET / HTTP/1.1\r\nHost: aexample.com\r\n\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewHTTPStrategy(tt.strategy)
+			require.NoError(t, err)
+
+			s.ConnectAuthority = true
+			got, err := s.Apply([]byte(tt.req))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+
+	// without the option, the host header is modified as with any other request.
+	s, err := NewHTTPStrategy("[HTTP:host:*]-insert{a:start:value}-|")
+	require.NoError(t, err)
+
+	got, err := s.Apply([]byte("CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "CONNECT example.com:443 HTTP/1.1\r\nHost: aexample.com:443\r\n\r\n", string(got))
+}
+
+func TestHTTPStrategy_ApplyBinaryBody(t *testing.T) {
+	s, err := NewHTTPStrategy("[HTTP:host:*]-insert{%20:start:name:1}-|[HTTP:path:*]-insert{%20:start:value:1}-|")
+	require.NoError(t, err)
+
+	body := []byte("\x1f\x8b\x08\x00\x00\r\n\r\n\xff\xfe\x80Host: a.com\x00")
+	req := append([]byte("POST / HTTP/1.1\r\nHost: example.com\r\n\r\n"), body...)
+
+	got, err := s.Apply(req)
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte("POST  / HTTP/1.1\r\n Host: example.com\r\n\r\n"), body...), got)
+}
+
 func Test_parseRule(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -79,6 +695,106 @@ func Test_parseTrigger(t *testing.T) {
 				matchStr:    "*",
 			},
 			wantErr: false,
+		}, {
+			name:    "encoded colon in match string",
+			trigger: "[HTTP:host:example.com%3A8080]",
+			want: trigger{
+				proto:       "HTTP",
+				targetField: "host",
+				matchStr:    "example.com:8080",
+			},
+			wantErr: false,
+		}, {
+			name:    "wildcard in match string",
+			trigger: "[HTTP:host:*.example.com]",
+			want: trigger{
+				proto:       "HTTP",
+				targetField: "host",
+				matchStr:    "*.example.com",
+				glob:        []string{"", ".example.com"},
+			},
+			wantErr: false,
+		}, {
+			name:    "absent header",
+			trigger: "[HTTP:!Host:*]",
+			want: trigger{
+				proto:       "HTTP",
+				targetField: "host",
+				matchStr:    "*",
+				absent:      true,
+			},
+			wantErr: false,
+		}, {
+			name:    "joined header",
+			trigger: "[HTTP:+Cookie:*]",
+			want: trigger{
+				proto:       "HTTP",
+				targetField: "cookie",
+				matchStr:    "*",
+				joined:      true,
+			},
+			wantErr: false,
+		}, {
+			name:    "joined and decoded header",
+			trigger: "[HTTP:+~Cookie:*]",
+			want: trigger{
+				proto:       "HTTP",
+				targetField: "cookie",
+				matchStr:    "*",
+				joined:      true,
+				decoded:     true,
+			},
+			wantErr: false,
+		}, {
+			name:    "decoded path",
+			trigger: "[HTTP:~path:*]",
+			want: trigger{
+				proto:       "HTTP",
+				targetField: "path",
+				matchStr:    "*",
+				decoded:     true,
+			},
+			wantErr: false,
+		}, {
+			name:    "error: absent and decoded header",
+			trigger: "[HTTP:!~host:*]",
+			want:    trigger{},
+			wantErr: true,
+		}, {
+			name:    "error: joined and decoded non-header field",
+			trigger: "[HTTP:+~path:*]",
+			want:    trigger{},
+			wantErr: true,
+		}, {
+			name:    "error: absent query parameter",
+			trigger: "[HTTP:!query.q:*]",
+			want:    trigger{},
+			wantErr: true,
+		}, {
+			name:    "error: query parameter without name",
+			trigger: "[HTTP:query.:*]",
+			want:    trigger{},
+			wantErr: true,
+		}, {
+			name:    "error: joined non-header field",
+			trigger: "[HTTP:+method:*]",
+			want:    trigger{},
+			wantErr: true,
+		}, {
+			name:    "error: absent and joined header",
+			trigger: "[HTTP:!+cookie:*]",
+			want:    trigger{},
+			wantErr: true,
+		}, {
+			name:    "error: absent non-header field",
+			trigger: "[HTTP:!path:*]",
+			want:    trigger{},
+			wantErr: true,
+		}, {
+			name:    "error: invalid escape in match string",
+			trigger: "[HTTP:host:example.com%3]",
+			want:    trigger{},
+			wantErr: true,
 		}, {
 			name:    "error: invalid format",
 			trigger: "[http:path:*",
@@ -104,6 +820,83 @@ func Test_parseTrigger(t *testing.T) {
 	}
 }
 
+func TestTrigger_MatchValue(t *testing.T) {
+	tests := []struct {
+		matchStr string
+		value    string
+		want     bool
+	}{
+		{"*", "anything", true},
+		{"example.com", "example.com", true},
+		{"example.com", "www.example.com", false},
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "www.example.org", false},
+		{"www.*", "www.example.org", true},
+		{"www.*.com", "www.example.com", true},
+		{"www.*.com", "www.com", false},
+		{"a*b*c", "abc", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "acb", false},
+		{"ab*ba", "aba", false},
+		{"example.com", "Example.COM", true},
+		{"*.example.com", "WWW.Example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.matchStr+" "+tt.value, func(t *testing.T) {
+			trig, err := parseTrigger("[HTTP:host:" + tt.matchStr + "]")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, trig.matchValue(tt.value))
+		})
+	}
+}
+
+func TestTrigger_String(t *testing.T) {
+	trig, err := parseTrigger("[http:host:*]")
+	require.NoError(t, err)
+	assert.Equal(t, "[HTTP:host:*]", trig.String())
+	assert.Equal(t, "[HTTP:host:*]", fmt.Sprint(&trig))
+
+	trig, err = parseTrigger("[http:!host:*]")
+	require.NoError(t, err)
+	assert.Equal(t, "[HTTP:!host:*]", trig.String())
+
+	trig, err = parseTrigger("[http:+~Cookie:*]")
+	require.NoError(t, err)
+	assert.Equal(t, "[HTTP:+~cookie:*]", trig.String())
+
+	trig, err = parseTrigger("[http:host:example.com%3a8080]")
+	require.NoError(t, err)
+	assert.Equal(t, "[HTTP:host:example.com%3A8080]", trig.String())
+}
+
+func TestTrigger_StringRoundTrip(t *testing.T) {
+	for _, str := range []string{
+		"[HTTP:host:*]",
+		"[HTTP:host:*.example.com]",
+		"[HTTP:host:example.com%3A8080]",
+		"[HTTP:host:my%2Dhost.com]",
+		"[HTTP:path:/100%25]",
+		"[HTTP:path:a%7Cb]",
+		"[HTTP:path:%5Ba%5D%7Bb%7D%28c%29%2Cd]",
+		"[HTTP:~path:%2541]",
+	} {
+		t.Run(str, func(t *testing.T) {
+			trig, err := parseTrigger(str)
+			require.NoError(t, err)
+
+			got, err := parseTrigger(trig.String())
+			require.NoError(t, err)
+			assert.Equal(t, trig, got)
+
+			// the trigger must also be usable in a rule.
+			_, err = NewHTTPStrategy(trig.String() + "-changecase{lower}-|")
+			assert.NoError(t, err)
+		})
+	}
+}
+
 func Test_parseAction(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -238,6 +1031,29 @@ func Test_applyModifications(t *testing.T) {
 			},
 			want: "GET /route HTTP/1.1\r\naaaaa: localhost\r\nHost: localhost\r\n\r\nsome data",
 		},
+		{
+			name: "modify header per branch",
+			field: field{
+				name:     "Host",
+				value:    "localhost",
+				ows:      " ",
+				isHeader: true,
+			},
+			mods: []field{
+				{
+					name:     "PUT",
+					value:    "localhost",
+					ows:      " ",
+					isHeader: true,
+				},
+				{
+					name:     "host",
+					value:    "localhost",
+					isHeader: true,
+				},
+			},
+			want: "GET /route HTTP/1.1\r\nPUT: localhost\r\nhost:localhost\r\n\r\nsome data",
+		},
 	}
 	for _, tt := range tests {
 		req := testReq()
@@ -270,3 +1086,44 @@ func testIfErrorOrEqual(t *testing.T, wantErr bool, err error, want interface{},
 		assert.Equal(t, want, got)
 	}
 }
+
+func TestHTTPStrategy_EstimateExpansion(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		want     int
+	}{
+		{
+			name:     "light",
+			strategy: "[HTTP:method:*]-changecase{lower}-|[HTTP:host:*]-insert{%20:start:name:1}-|",
+			want:     1,
+		}, {
+			name:     "heavy",
+			strategy: "[HTTP:host:*]-insert{%20:end:value:4081}-|",
+			want:     4081,
+		}, {
+			name:     "replace",
+			strategy: "[HTTP:path:*]-replace{abc:value:2}-|",
+			want:     6,
+		}, {
+			name:     "insert before duplicate",
+			strategy: "[HTTP:host:*]-insert{ab:end:value:1}(duplicate(,insert{c:start:value:3}),)-|",
+			want:     7,
+		}, {
+			name:     "variable",
+			strategy: "[HTTP:path:*]-insert{$host:end:value}-|",
+			want:     0,
+		}, {
+			name:     "no-op",
+			strategy: "[HTTP:host:*]--|",
+			want:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewHTTPStrategy(tt.strategy)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s.EstimateExpansion())
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package algeneva
+
+import (
+	"bytes"
+	"io"
+)
+
+// Transformer applies a strategy to a request as it is copied from a source to a destination. Transformer
+// implements io.ReaderFrom and io.WriterTo:
+//
+//	t := strategy.Transformer()
+//	if _, err := t.ReadFrom(src); err != nil { ... }
+//	if _, err := t.WriteTo(dst); err != nil { ... }
+//
+// The request is buffered in full since the strategy can only be applied once all of the headers have been read.
+// The body, if any, is passed through unmodified, as with Apply.
+type Transformer struct {
+	strategy *HTTPStrategy
+	buf      bytes.Buffer
+}
+
+// Transformer returns a new Transformer that applies s.
+func (s *HTTPStrategy) Transformer() *Transformer {
+	return &Transformer{strategy: s}
+}
+
+// ReadFrom reads a request from r until EOF and applies the strategy to it. The transformed request can then be
+// retrieved with WriteTo. ReadFrom returns the number of bytes read from r. If the strategy cannot be applied, the
+// error is returned and nothing is buffered.
+func (t *Transformer) ReadFrom(r io.Reader) (int64, error) {
+	var in bytes.Buffer
+	n, err := in.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+
+	out, err := t.strategy.Apply(in.Bytes())
+	if err != nil {
+		return n, err
+	}
+
+	t.buf.Write(out)
+	return n, nil
+}
+
+// WriteTo writes the transformed request to w and returns the number of bytes written.
+func (t *Transformer) WriteTo(w io.Writer) (int64, error) {
+	return t.buf.WriteTo(w)
+}
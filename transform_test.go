@@ -0,0 +1,43 @@
+package algeneva
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransformer(t *testing.T) {
+	s, err := NewHTTPStrategy("[HTTP:host:*]-changecase{upper}-|")
+	require.NoError(t, err)
+
+	req := "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 9\r\n\r\nsome body"
+	want := "POST / HTTP/1.1\r\nHOST: EXAMPLE.COM\r\nContent-Length: 9\r\n\r\nsome body"
+
+	tr := s.Transformer()
+	n, err := tr.ReadFrom(strings.NewReader(req))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(req)), n)
+
+	var dst bytes.Buffer
+	n, err = tr.WriteTo(&dst)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(want)), n)
+	assert.Equal(t, want, dst.String())
+}
+
+func TestTransformer_InvalidRequest(t *testing.T) {
+	s, err := NewHTTPStrategy("[HTTP:host:*]-changecase{upper}-|")
+	require.NoError(t, err)
+
+	tr := s.Transformer()
+	_, err = tr.ReadFrom(strings.NewReader("GET / HTTP/1.1\r\nHost: example.com"))
+	assert.Error(t, err)
+
+	n, err := tr.WriteTo(io.Discard)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}